@@ -5,12 +5,20 @@ package archivehelpers
 
 import (
 	"archive/tar"
+	"compress/bzip2"
+	"compress/flate"
 	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"iter"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -18,16 +26,236 @@ const (
 	TypeUnknown Type = iota
 	// TypeTarGz is a tar.gz archive.
 	TypeTarGz
+	// TypeZip is a zip archive.
+	TypeZip
+	// TypeTar is an uncompressed tar archive.
+	TypeTar
+	// TypeTarBz2 is a tar.bz2 archive.
+	// Only extraction is supported, as the standard library has no bzip2 compressor.
+	TypeTarBz2
 )
 
-// New returns a new Archiver for the given type.
+// New returns a new Archiver for the given type using the default options.
 func New(typ Type) (Archiver, error) {
-	switch typ {
-	case TypeTarGz:
-		return &archivist{archiver: &tarGzExtractor{}}, nil
-	default:
+	return NewWithOptions(typ, Options{})
+}
+
+// NewWithOptions returns a new Archiver for the given type and options.
+func NewWithOptions(typ Type, opts Options) (Archiver, error) {
+	a, err := newArchivist(typ, opts)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func newArchivist(typ Type, opts Options) (*archivist, error) {
+	opts.Extract.Limits = opts.Extract.Limits.init()
+	if opts.Extract.XattrNamespaces == nil {
+		opts.Extract.XattrNamespaces = defaultXattrNamespaces
+	}
+	opts.Archive = opts.Archive.init()
+
+	ti := lookupType(typ)
+	if ti == nil {
 		return nil, fmt.Errorf("unknown type %d", typ)
 	}
+
+	if ti.flate {
+		if level := opts.Archive.compressionLevel(); level < flate.HuffmanOnly || level > flate.BestCompression {
+			return nil, fmt.Errorf("invalid compression level %d", level)
+		}
+	}
+
+	if key := opts.Archive.SigningKey; key != nil {
+		if !supportsEmbeddedSignature(typ) {
+			return nil, fmt.Errorf("signing is not supported for type %s", typ)
+		}
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid ed25519 private key size")
+		}
+	}
+	if opts.Extract.Atomic && opts.Extract.OnConflict != ConflictOverwrite {
+		return nil, errors.New("option Atomic can not be combined with OnConflict, the target directory is replaced as a whole")
+	}
+	if opts.Extract.Signature != nil && len(opts.Extract.PublicKeys) == 0 {
+		return nil, errors.New("a signature can not be verified without PublicKeys")
+	}
+	if keys := opts.Extract.PublicKeys; keys != nil {
+		if err := validatePublicKeys(keys); err != nil {
+			return nil, err
+		}
+	}
+
+	return &archivist{archiver: ti.newArchiver(), typ: typ, opts: opts}, nil
+}
+
+// Options configures an Archiver.
+type Options struct {
+	// Archive configures ArchiveDirectory.
+	Archive ArchiveOptions
+
+	// Extract configures Extract.
+	Extract ExtractOptions
+}
+
+// ArchiveOptions configures ArchiveDirectory.
+type ArchiveOptions struct {
+	// Reproducible makes ArchiveDirectory produce byte identical archives for identical
+	// file content and names: modification times are set to (or clamped to) ModTime,
+	// owner information is cleared, permissions are normalized to 0644 or 0755
+	// (if any execute bit is set) and no file system metadata ends up in the gzip header.
+	// Entries are always added in lexical order.
+	Reproducible bool
+
+	// ModTime is used in Reproducible mode.
+	// If set, any modification time after ModTime is clamped to ModTime.
+	// If not set, the SOURCE_DATE_EPOCH environment variable is used if set.
+	// If neither is set, all modification times are set to 1980-01-01 00:00:00 UTC,
+	// the earliest time that can be represented in a zip archive.
+	ModTime time.Time
+
+	// Progress, if set, is called as files are archived.
+	Progress func(Progress)
+
+	// IncludeDirectories adds directories, including empty ones, as separate entries,
+	// preserving their permissions and modification times.
+	// The predicate is then also called for directories;
+	// excluding a directory does not exclude the files below it.
+	IncludeDirectories bool
+
+	// CompressionLevel is the compression level to use, see compress/flate.
+	// The zero value means flate.BestCompression, use NoCompression to store files uncompressed.
+	CompressionLevel int
+
+	// NoCompression stores files without compression, overriding CompressionLevel.
+	NoCompression bool
+
+	// CompressionWorkers, if greater than 1, compresses tar.gz archives in blocks
+	// using up to this many goroutines in parallel.
+	// The result is a standard multi-member gzip stream.
+	CompressionWorkers int
+
+	// Checksums records the SHA-256 digest of each regular file in the archive,
+	// in a PAX record for tar archives and in the file comment for zip archives.
+	// Note that this reads every file twice.
+	// See ExtractOptions.VerifyChecksums.
+	Checksums bool
+
+	// Xattrs records the extended attributes of files and directories, e.g. SELinux labels,
+	// as SCHILY.xattr PAX records in tar archives.
+	// This is only supported on Linux and for files on the OS file system, and is ignored for zip archives.
+	// See ExtractOptions.RestoreXattrs.
+	Xattrs bool
+
+	// SigningKey, if set, embeds an ed25519 signature of the SHA-256 digest of the archive at its end:
+	// in an empty trailing gzip member for tar.gz archives and in the archive comment for zip archives.
+	// Other types are not supported. See ExtractOptions.PublicKeys and SignArchive for detached signatures.
+	SigningKey ed25519.PrivateKey
+}
+
+// ExtractOptions configures Extract.
+type ExtractOptions struct {
+	// Limits protects against decompression bombs.
+	// The zero value uses safe defaults, see Limits.
+	Limits Limits
+
+	// Progress, if set, is called as entries are extracted.
+	Progress func(Progress)
+
+	// RestoreDirectoryMetadata restores the permissions and modification times of
+	// the directories in the archive once all files inside them are written.
+	// If not set, directories are created with the permissions in the archive.
+	RestoreDirectoryMetadata bool
+
+	// StripComponents removes this many leading path elements from the entry names,
+	// like tar's --strip-components.
+	// Entries with no path elements left are skipped.
+	StripComponents int
+
+	// Filter, if set, is called for each entry after StripComponents is applied.
+	// Only entries for which it returns true are extracted.
+	Filter func(Entry) bool
+
+	// Rename, if set, is called for each entry name after Filter.
+	// It returns the slash separated path, relative to the target directory, to extract the entry to,
+	// or false to skip the entry.
+	// Hard link targets are renamed the same way.
+	Rename func(name string) (string, bool)
+
+	// OnConflict decides what to do with files that already exist.
+	// The default is ConflictOverwrite.
+	OnConflict ConflictPolicy
+
+	// OnWrite, if set, is called for every file and link written to disk
+	// with the path written to and whether it replaced an existing file.
+	// With Atomic set, nothing is written to the existing target directory, so replaced is always false.
+	OnWrite func(filename string, replaced bool)
+
+	// PreserveTimes restores the modification and access times of files and directories.
+	// If the archive has no access times, the modification times are used.
+	PreserveTimes bool
+
+	// PreservePermissions applies the permissions in the archive, masked by Umask,
+	// regardless of the process umask.
+	// If not set, files and directories are created with the permissions in the archive
+	// masked by both Umask and the process umask.
+	// Only the permission bits are restored, never setuid, setgid or sticky bits.
+	PreservePermissions bool
+
+	// Umask is masked away from the permissions in the archive.
+	Umask fs.FileMode
+
+	// PreserveOwner restores the owner and group IDs of files, links and directories.
+	// This is only done when running as root on Unix systems.
+	PreserveOwner bool
+
+	// Atomic extracts into a temporary directory next to the target directory,
+	// which replaces the target directory as a whole on success.
+	// Any existing files in the target directory that are not in the archive are discarded,
+	// so this can not be combined with OnConflict.
+	// On failure, the temporary directory is removed and the target directory is left untouched.
+	// Note that any existing target directory is moved away right before the temporary
+	// directory is moved into place, so it may briefly not exist, but it will never contain
+	// a mix of old and new files.
+	Atomic bool
+
+	// VerifyChecksums verifies the content of every regular file against the digest
+	// recorded with ArchiveOptions.Checksums as it is extracted.
+	// A mismatch or a file without a digest fails with a ChecksumError
+	// and the offending file is removed.
+	VerifyChecksums bool
+
+	// ContinueOnError skips entries that fail to extract and continues with the rest.
+	// The errors, each an *ExtractError, are returned joined when done.
+	// Cancellation, exceeded Limits and errors reading the archive itself still stop the extraction.
+	// With Atomic set, any error still leaves the target directory untouched.
+	ContinueOnError bool
+
+	// RestoreXattrs restores the extended attributes stored as SCHILY.xattr PAX records
+	// in tar archives on files and directories.
+	// Only the namespaces in XattrNamespaces are restored.
+	// This is only supported on Linux; attributes not supported by the file system are skipped.
+	RestoreXattrs bool
+
+	// XattrNamespaces are the extended attribute namespaces restored with RestoreXattrs,
+	// e.g. "user" for "user.*" attributes.
+	// The default is "user" only, as attributes in other namespaces, e.g. security.capability,
+	// may grant privileges that PreservePermissions never restores.
+	// Note that restoring attributes outside the user namespace usually requires root.
+	XattrNamespaces []string
+
+	// PublicKeys, if set, makes Extract verify the signature of the archive against these keys
+	// before anything is written, failing with an error wrapping ErrInvalidSignature if none of them match.
+	// The archive is first copied to a private temporary file, which is what is verified and extracted,
+	// so changes to the source made during the extraction can not bypass the verification.
+	PublicKeys []ed25519.PublicKey
+
+	// Signature is a detached signature, see SignArchive, to verify against PublicKeys,
+	// which must then be set.
+	// If not set, the signature embedded with ArchiveOptions.SigningKey is used.
+	// It can not be used with ExtractChain on more than one archive.
+	Signature []byte
 }
 
 // Archiver is an interface for archiving files and directories.
@@ -38,55 +266,256 @@ type Archiver interface {
 	// for all files matching predicate.
 	// out is closed by this method.
 	ArchiveDirectory(directory string, predicate func(string) bool, out io.WriteCloser) error
+
+	// ArchiveDirectoryContext is like ArchiveDirectory, but stops when ctx is cancelled.
+	// If out is an *os.File, it is removed on cancellation.
+	// Progress is reported to ArchiveOptions.Progress if set.
+	ArchiveDirectoryContext(ctx context.Context, directory string, predicate func(string) bool, out io.WriteCloser) error
+
+	// ArchiveFS archives the given root in fsys into the given output stream
+	// for all files matching predicate.
+	// The predicate receives the slash separated path in fsys.
+	// Symlinks are stored as links if fsys implements fs.ReadLinkFS, else they are followed.
+	// out is closed by this method.
+	ArchiveFS(fsys fs.FS, root string, predicate func(string) bool, out io.WriteCloser) error
+
+	// ArchiveDirectoryIncremental is like ArchiveDirectoryContext, but only archives the files that are new
+	// or changed (size, mode, modification time or link target) compared to prev,
+	// and records the files in prev that are gone as whiteout entries, e.g. "a/.wh.b.txt" for "a/b.txt".
+	// If prev is nil, all files are archived.
+	// Files with names starting with ".wh." are reserved for whiteouts and fail the archiving.
+	// It returns the Snapshot to pass as prev for the next incremental archive.
+	// Use ExtractChain to extract the base archive and its incremental archives.
+	ArchiveDirectoryIncremental(ctx context.Context, directory string, predicate func(string) bool, prev *Snapshot, out io.WriteCloser) (*Snapshot, error)
+
+	// ExtractChain extracts the given archives in order into targetDir,
+	// typically a base archive followed by incremental archives created with ArchiveDirectoryIncremental.
+	// Whiteout entries remove the files they mark as deleted;
+	// any entry with a name starting with ".wh." is treated as a whiteout.
+	// The archives are closed by this method.
+	ExtractChain(ctx context.Context, targetDir string, archives ...io.ReadCloser) error
+
+	// ArchiveFiles archives the given files, in order, into the given output stream,
+	// each under its own path in the archive.
+	// Directories are added as directory entries without their content.
+	// out is closed by this method.
+	ArchiveFiles(files []File, out io.WriteCloser) error
+
+	// Entries returns an iterator over the entries in the given archive without extracting anything.
+	// Iteration stops after the first error.
+	// in is closed when the iteration is done.
+	// Zip archives need random access, so unless in is a regular file or an *io.SectionReader,
+	// a zip archive is spooled to a temporary file before the first entry is returned.
+	Entries(in io.ReadCloser) iter.Seq2[Entry, error]
 }
 
 // Extracter is an interface for extracting archives.
 type Extracter interface {
 	// Extract extracts the given archive into the given directory.
+	// Zip archives need random access, so unless in is a regular file or an *io.SectionReader,
+	// a zip archive is spooled to a temporary file first.
 	Extract(in io.ReadCloser, targetDir string) error
+
+	// ExtractContext is like Extract, but stops when ctx is cancelled.
+	// Any files and directories created by this call are removed on cancellation.
+	// Progress is reported to ExtractOptions.Progress if set.
+	ExtractContext(ctx context.Context, in io.ReadCloser, targetDir string) error
 }
 
 // Type represents an archive type.
 type Type int
 
 func (t Type) String() string {
-	switch t {
-	case TypeTarGz:
-		return "tar.gz"
-	default:
-		return "unknown"
+	if ti := lookupType(t); ti != nil {
+		return ti.name
 	}
+	return "unknown"
 }
 
 type archiveAdder interface {
-	Add(filename string, info os.FileInfo, targetPath string) error
+	Add(src fileSource, info fs.FileInfo, targetPath string) error
 	Close() error
 }
 
+// fileSource provides the content of a file to archive.
+type fileSource interface {
+	Open() (io.ReadCloser, error)
+	Readlink() (string, error)
+
+	// Xattrs returns the extended attributes of the file, if supported.
+	Xattrs() (map[string]string, error)
+}
+
+// osFile is a fileSource for a file on the OS file system.
+type osFile string
+
+func (f osFile) Open() (io.ReadCloser, error) {
+	return os.Open(string(f))
+}
+
+func (f osFile) Readlink() (string, error) {
+	return os.Readlink(string(f))
+}
+
+func (f osFile) Xattrs() (map[string]string, error) {
+	return readXattrs(string(f))
+}
+
+// fsFile is a fileSource for a file in a fs.FS.
+type fsFile struct {
+	fsys fs.FS
+	name string
+}
+
+func (f fsFile) Open() (io.ReadCloser, error) {
+	return f.fsys.Open(f.name)
+}
+
+func (f fsFile) Readlink() (string, error) {
+	return fs.ReadLink(f.fsys, f.name)
+}
+
+func (f fsFile) Xattrs() (map[string]string, error) {
+	return nil, nil
+}
+
 type archiver interface {
-	Extracter
-	NewArchiveAdder(out io.WriteCloser) archiveAdder
+	NewArchiveAdder(out io.WriteCloser, opts ArchiveOptions) (archiveAdder, error)
+	NewEntryReader(in io.Reader) (entryReader, error)
+}
+
+// entryReader reads the entries of an archive in order.
+type entryReader interface {
+	// Next advances to the next entry and returns its header and content.
+	// It returns io.EOF when there are no more entries.
+	Next() (*tar.Header, io.Reader, error)
+
+	// BytesRead returns the number of (compressed) bytes read from the archive so far.
+	BytesRead() int64
+
+	Close() error
 }
 
 type archivist struct {
 	archiver
+	typ  Type
+	opts Options
+}
+
+func (a *archivist) Extract(in io.ReadCloser, targetDir string) error {
+	return a.ExtractContext(context.Background(), in, targetDir)
 }
 
-func (a *archivist) ArchiveDirectory(directory string, predicate func(string) bool, out io.WriteCloser) (err error) {
-	archive := a.archiver.NewArchiveAdder(out)
+func (a *archivist) ExtractContext(ctx context.Context, in io.ReadCloser, targetDir string) error {
+	defer in.Close()
+
+	r, cleanup, err := a.verify(in, a.opts.Extract.Signature)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return a.extractArchives(ctx, targetDir, false, r)
+}
+
+// extractArchives extracts the given archives in order into targetDir,
+// applying whiteout entries if whiteouts is set.
+func (a *archivist) extractArchives(ctx context.Context, targetDir string, whiteouts bool, archives ...io.Reader) error {
+	if a.opts.Extract.Atomic {
+		return a.extractAtomic(targetDir, func(a *archivist, dir string) error {
+			return a.extractArchives(ctx, dir, whiteouts, archives...)
+		})
+	}
+
+	for _, in := range archives {
+		if err := a.extract(ctx, in, targetDir, whiteouts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archivist) extract(ctx context.Context, in io.Reader, targetDir string, whiteouts bool) (err error) {
+	r, err := a.archiver.NewEntryReader(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	x, err := newExtraction(ctx, targetDir, r, a.opts.Extract)
+	if err != nil {
+		return err
+	}
+	x.whiteouts = whiteouts
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			x.removeCreated()
+		}
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, content, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return x.fail(err)
+		}
+
+		header, ok := x.mapHeader(header)
+		if !ok {
+			continue
+		}
+
+		if err := x.extractEntry(header, content); err != nil {
+			if err := x.handleError(err); err != nil {
+				return err
+			}
+			continue
+		}
+		x.entriesDone++
+		x.progress(header.Name)
+	}
+
+	return x.finish()
+}
+
+func (a *archivist) ArchiveDirectory(directory string, predicate func(string) bool, out io.WriteCloser) error {
+	return a.ArchiveDirectoryContext(context.Background(), directory, predicate, out)
+}
+
+func (a *archivist) ArchiveDirectoryContext(ctx context.Context, directory string, predicate func(string) bool, out io.WriteCloser) (err error) {
+	archive, err := a.newArchiveAdder(out)
+	if err != nil {
+		out.Close()
+		return err
+	}
 	defer func() {
 		closeErr := archive.Close()
 		if err == nil {
 			err = closeErr
 		}
+		if err != nil && ctx.Err() != nil {
+			removeOutput(out)
+		}
 	}()
 
+	p := &archiveProgress{ctx: ctx, fn: a.opts.Archive.Progress}
+
 	err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if info.IsDir() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if info.IsDir() && (!a.opts.Archive.IncludeDirectories || path == directory) {
 			return nil
 		}
 
@@ -95,37 +524,147 @@ func (a *archivist) ArchiveDirectory(directory string, predicate func(string) bo
 		}
 
 		targetPath := strings.Trim(filepath.ToSlash(strings.TrimPrefix(path, directory)), "/")
-		return archive.Add(path, info, targetPath)
+		return p.add(archive, osFile(path), info, targetPath)
 	})
 
 	return
 }
 
-type tarGzArchiver struct {
+func (a *archivist) ArchiveFS(fsys fs.FS, root string, predicate func(string) bool, out io.WriteCloser) (err error) {
+	archive, err := a.newArchiveAdder(out)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	defer func() {
+		closeErr := archive.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, canReadLink := fsys.(fs.ReadLinkFS)
+	p := &archiveProgress{ctx: context.Background(), fn: a.opts.Archive.Progress}
+
+	err = fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() && (!a.opts.Archive.IncludeDirectories || path == root) {
+			return nil
+		}
+
+		if !predicate(path) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 && !canReadLink {
+			// Archive what the symlink points to.
+			info, err = fs.Stat(fsys, path)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+		}
+
+		targetPath := path
+		if root != "." {
+			targetPath = strings.Trim(strings.TrimPrefix(path, root), "/")
+		}
+		return p.add(archive, fsFile{fsys: fsys, name: path}, info, targetPath)
+	})
+
+	return
+}
+
+type tarArchiver struct {
 	out io.WriteCloser
-	gw  *gzip.Writer
-	tw  *tar.Writer
+	cw  io.WriteCloser
+	tw  FormatWriter
+
+	// Maps files with more than one link to the first target path they were added as.
+	links map[fileKey]string
+
+	opts ArchiveOptions
 }
 
-func (a *tarGzArchiver) Add(filename string, info os.FileInfo, targetPath string) error {
-	f, err := os.Open(filename)
+func (a *tarArchiver) Add(src fileSource, info fs.FileInfo, targetPath string) error {
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		var err error
+		link, err = src.Readlink()
+		if err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
 	if err != nil {
 		return err
 	}
+	header.Name = targetPath
+	a.opts.normalizeTarHeader(header)
+
+	if header.Typeflag == tar.TypeReg {
+		if key, ok := hardLinkKey(info); ok {
+			if first, found := a.links[key]; found {
+				header.Typeflag = tar.TypeLink
+				header.Linkname = first
+				header.Size = 0
+			} else {
+				a.links[key] = targetPath
+			}
+		}
+	}
 
-	defer f.Close()
+	if a.opts.Xattrs && (header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeDir) {
+		xattrs, err := src.Xattrs()
+		if err != nil {
+			return err
+		}
+		for name, value := range xattrs {
+			if header.PAXRecords == nil {
+				header.PAXRecords = make(map[string]string)
+			}
+			header.PAXRecords[paxXattr+name] = value
+		}
+	}
+
+	if a.opts.Checksums && header.Typeflag == tar.TypeReg {
+		sum, err := fileChecksum(src)
+		if err != nil {
+			return err
+		}
+		if header.PAXRecords == nil {
+			header.PAXRecords = make(map[string]string)
+		}
+		header.PAXRecords[paxSHA256] = sum
+	}
 
-	header, err := tar.FileInfoHeader(info, "") // TODO(bep) symlink handling?
+	err = a.tw.WriteHeader(header)
 	if err != nil {
 		return err
 	}
-	header.Name = targetPath
 
-	err = a.tw.WriteHeader(header)
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	f, err := src.Open()
 	if err != nil {
 		return err
 	}
 
+	defer f.Close()
+
 	_, err = io.Copy(a.tw, f)
 	if err != nil {
 		return err
@@ -134,83 +673,138 @@ func (a *tarGzArchiver) Add(filename string, info os.FileInfo, targetPath string
 	return nil
 }
 
-func (a *tarGzArchiver) Close() error {
+func (a *tarArchiver) Close() error {
 	if err := a.tw.Close(); err != nil {
 		return err
 	}
-	if err := a.gw.Close(); err != nil {
+	if err := a.cw.Close(); err != nil {
 		return err
 	}
 
 	return a.out.Close()
 }
 
-type tarGzExtractor struct{}
+// tarExtractor handles tar archives compressed with the given codec.
+type tarExtractor struct {
+	// newCompressor is nil if archiving is not supported.
+	newCompressor   func(w io.Writer, opts ArchiveOptions) (io.WriteCloser, error)
+	newDecompressor func(r io.Reader) (io.ReadCloser, error)
+
+	// newWriter and newReader, if set, replace the tar format for registered formats, see Format.
+	newWriter func(w io.Writer, opts ArchiveOptions) (FormatWriter, error)
+	newReader func(r io.Reader) (FormatReader, error)
+}
 
-func (e *tarGzExtractor) NewArchiveAdder(out io.WriteCloser) archiveAdder {
-	a := &tarGzArchiver{
-		out: out,
+func (e *tarExtractor) NewArchiveAdder(out io.WriteCloser, opts ArchiveOptions) (archiveAdder, error) {
+	if e.newCompressor == nil {
+		return nil, errors.New("archiving is not supported for this type")
 	}
 
-	gw, _ := gzip.NewWriterLevel(out, gzip.BestCompression)
-	tw := tar.NewWriter(gw)
+	cw, err := e.newCompressor(out, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	a.gw = gw
-	a.tw = tw
+	var tw FormatWriter = tar.NewWriter(cw)
+	if e.newWriter != nil {
+		if tw, err = e.newWriter(cw, opts); err != nil {
+			cw.Close()
+			return nil, err
+		}
+	}
 
-	return struct {
-		archiveAdder
-		Extracter
-	}{
-		a,
-		&tarGzExtractor{},
+	return &tarArchiver{
+		out:   out,
+		cw:    cw,
+		tw:    tw,
+		links: make(map[fileKey]string),
+		opts:  opts,
+	}, nil
+}
+
+func (e *tarExtractor) NewEntryReader(in io.Reader) (entryReader, error) {
+	cr := &countingReader{r: in}
+	rc, err := e.newDecompressor(cr)
+	if err != nil {
+		return nil, err
 	}
+
+	var tr FormatReader = tar.NewReader(rc)
+	if e.newReader != nil {
+		if tr, err = e.newReader(rc); err != nil {
+			rc.Close()
+			return nil, err
+		}
+	}
+
+	return &tarEntryReader{tr: tr, cr: cr, closer: rc}, nil
 }
 
-func (e *tarGzExtractor) Extract(in io.ReadCloser, targetDir string) error {
-	defer in.Close()
+// Note that the gzip header is left empty, so it is stable
+// (e.g. no modification time), see ArchiveOptions.Reproducible.
+func newGzipCompressor(w io.Writer, opts ArchiveOptions) (io.WriteCloser, error) {
+	if opts.CompressionWorkers > 1 {
+		return newParallelGzipWriter(w, opts.compressionLevel(), opts.CompressionWorkers), nil
+	}
+	gw, err := gzip.NewWriterLevel(w, opts.compressionLevel())
+	if err != nil {
+		return nil, err
+	}
+	return gw, nil
+}
 
-	gzr, err := gzip.NewReader(in)
+func newGzipDecompressor(r io.Reader) (io.ReadCloser, error) {
+	gzr, err := gzip.NewReader(r)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return gzr, nil
+}
 
-	tr := tar.NewReader(gzr)
+func newNopCompressor(w io.Writer, opts ArchiveOptions) (io.WriteCloser, error) {
+	return nopCloser{w}, nil
+}
 
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
+func newNopDecompressor(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
 
-		target := filepath.Join(targetDir, header.Name)
+func newBzip2Decompressor(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(r)), nil
+}
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, os.FileMode(header.Mode)); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil && !os.IsExist(err) {
-				return err
-			}
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
+// nopCloser is an io.WriteCloser with a no-op Close method.
+type nopCloser struct {
+	io.Writer
+}
 
-			if _, err := io.Copy(f, tr); err != nil {
-				return err
-			}
+func (nopCloser) Close() error { return nil }
+
+type tarEntryReader struct {
+	tr     FormatReader
+	cr     *countingReader
+	closer io.Closer
+}
 
-			f.Close()
-		default:
-			return fmt.Errorf("unable to untar type: %c in file %s", header.Typeflag, target)
+func (r *tarEntryReader) BytesRead() int64 {
+	return r.cr.n
+}
+
+func (r *tarEntryReader) Next() (*tar.Header, io.Reader, error) {
+	for {
+		header, err := r.tr.Next()
+		if err != nil {
+			return nil, nil, err
 		}
+		// PAX global headers, e.g. the commit ID written by git archive,
+		// carry metadata for the archive as a whole and are not files.
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		return header, r.tr, nil
 	}
+}
 
-	return nil
+func (r *tarEntryReader) Close() error {
+	return r.closer.Close()
 }
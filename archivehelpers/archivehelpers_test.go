@@ -4,12 +4,18 @@
 package archivehelpers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	qt "github.com/frankban/quicktest"
 )
@@ -21,11 +27,14 @@ func TestArchive(t *testing.T) {
 	c.Assert(err, qt.IsNotNil)
 
 	c.Assert(TypeTarGz.String(), qt.Equals, "tar.gz")
+	c.Assert(TypeZip.String(), qt.Equals, "zip")
+	c.Assert(TypeTar.String(), qt.Equals, "tar")
+	c.Assert(TypeTarBz2.String(), qt.Equals, "tar.bz2")
 	c.Assert(TypeUnknown.String(), qt.Equals, "unknown")
 
 	tempDir := t.TempDir()
 
-	for _, tp := range []Type{TypeTarGz} {
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
 
 		archiveFilename := filepath.Join(tempDir, "myarchive1."+tp.String())
 		f, err := os.Create(archiveFilename)
@@ -91,3 +100,138 @@ func TestArchive(t *testing.T) {
 		assertArchive(archiveFilename, matchSome)
 	}
 }
+
+func TestArchiveFS(t *testing.T) {
+	c := qt.New(t)
+
+	fsys := fstest.MapFS{
+		"public/index.html":     {Data: []byte("<html>"), Mode: 0o644},
+		"public/css/styles.css": {Data: []byte("body{}"), Mode: 0o600},
+		"public/css/skip.map":   {Data: []byte("map"), Mode: 0o644},
+		"other.txt":             {Data: []byte("other"), Mode: 0o644},
+	}
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		var seen []string
+		predicate := func(s string) bool {
+			seen = append(seen, s)
+			return !strings.HasSuffix(s, ".map")
+		}
+		c.Assert(a.ArchiveFS(fsys, "public", predicate, nopWriteCloser{&buf}), qt.IsNil)
+		c.Assert(seen, qt.DeepEquals, []string{"public/css/skip.map", "public/css/styles.css", "public/index.html"})
+
+		resultDir := t.TempDir()
+		c.Assert(a.Extract(io.NopCloser(&buf), resultDir), qt.IsNil)
+
+		b, err := os.ReadFile(filepath.Join(resultDir, "css", "styles.css"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "body{}")
+		fi, err := os.Stat(filepath.Join(resultDir, "css", "styles.css"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(fi.Mode().Perm(), qt.Equals, os.FileMode(0o600))
+		_, err = os.Stat(filepath.Join(resultDir, "css", "skip.map"))
+		c.Assert(os.IsNotExist(err), qt.IsTrue)
+		_, err = os.Stat(filepath.Join(resultDir, "index.html"))
+		c.Assert(err, qt.IsNil)
+
+		// The full FS.
+		buf.Reset()
+		c.Assert(a.ArchiveFS(fsys, ".", func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		var names []string
+		for e, err := range a.Entries(io.NopCloser(&buf)) {
+			c.Assert(err, qt.IsNil)
+			names = append(names, e.Name)
+		}
+		c.Assert(names, qt.DeepEquals, []string{"other.txt", "public/css/skip.map", "public/css/styles.css", "public/index.html"})
+	}
+}
+
+func TestArchiveDirectories(t *testing.T) {
+	c := qt.New(t)
+
+	mtime := time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "empty"), 0o755), qt.IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "sub", "subsub"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "sub", "subsub", "file.txt"), []byte("hello"), 0o644), qt.IsNil)
+	c.Assert(os.Chmod(filepath.Join(sourceDir, "empty"), 0o700), qt.IsNil)
+	c.Assert(os.Chmod(filepath.Join(sourceDir, "sub", "subsub"), 0o750), qt.IsNil)
+	for _, dir := range []string{"empty", "sub", "sub/subsub"} {
+		c.Assert(os.Chtimes(filepath.Join(sourceDir, dir), mtime, mtime), qt.IsNil)
+	}
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := NewWithOptions(tp, Options{
+			Archive: ArchiveOptions{IncludeDirectories: true},
+			Extract: ExtractOptions{RestoreDirectoryMetadata: true},
+		})
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		archive := buf.Bytes()
+
+		var names []string
+		for e, err := range a.Entries(io.NopCloser(bytes.NewReader(archive))) {
+			c.Assert(err, qt.IsNil)
+			names = append(names, fmt.Sprintf("%s %s", e.Type, e.Name))
+		}
+		c.Assert(names, qt.DeepEquals, []string{"dir empty", "dir sub", "dir sub/subsub", "file sub/subsub/file.txt"})
+
+		resultDir := t.TempDir()
+		c.Assert(a.Extract(io.NopCloser(bytes.NewReader(archive)), resultDir), qt.IsNil)
+
+		for _, test := range []struct {
+			dir  string
+			perm os.FileMode
+		}{
+			{"empty", 0o700},
+			{"sub", 0o755},
+			{"sub/subsub", 0o750},
+		} {
+			fi, err := os.Stat(filepath.Join(resultDir, filepath.FromSlash(test.dir)))
+			c.Assert(err, qt.IsNil)
+			c.Assert(fi.IsDir(), qt.IsTrue)
+			if runtime.GOOS != "windows" {
+				c.Assert(fi.Mode().Perm(), qt.Equals, test.perm, qt.Commentf("%s: %s", tp, test.dir))
+			}
+			c.Assert(fi.ModTime().Equal(mtime), qt.IsTrue, qt.Commentf("%s: %s: %s", tp, test.dir, fi.ModTime()))
+		}
+	}
+}
+
+func TestExtractTarBz2(t *testing.T) {
+	c := qt.New(t)
+
+	a, err := New(TypeTarBz2)
+	c.Assert(err, qt.IsNil)
+
+	f, err := os.Open(filepath.Join("testdata", "hello.tar.bz2"))
+	c.Assert(err, qt.IsNil)
+	typ, r, err := DetectType(f)
+	c.Assert(err, qt.IsNil)
+	c.Assert(typ, qt.Equals, TypeTarBz2)
+
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(r), targetDir), qt.IsNil)
+	c.Assert(f.Close(), qt.IsNil)
+	for name, content := range map[string]string{"a.txt": "hello", "dir/b.txt": "world"} {
+		b, err := os.ReadFile(filepath.Join(targetDir, filepath.FromSlash(name)))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, content)
+	}
+
+	// The same security checks apply as for the other tar formats.
+	f, err = os.Open(filepath.Join("testdata", "unsafe.tar.bz2"))
+	c.Assert(err, qt.IsNil)
+	var unsafeErr *UnsafePathError
+	c.Assert(errors.As(a.Extract(f, t.TempDir()), &unsafeErr), qt.IsTrue)
+
+	// Archiving is not supported.
+	c.Assert(a.ArchiveDirectory(t.TempDir(), func(string) bool { return true }, nopWriteCloser{io.Discard}), qt.ErrorMatches, "archiving is not supported.*")
+}
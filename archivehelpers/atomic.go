@@ -0,0 +1,84 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractAtomic calls extract with a temporary directory next to targetDir
+// and an archivist to extract with, and moves the directory into place as targetDir on success.
+func (a *archivist) extractAtomic(targetDir string, extract func(a *archivist, dir string) error) (err error) {
+	targetDir, err = filepath.Abs(targetDir)
+	if err != nil {
+		return err
+	}
+	parentDir, base := filepath.Split(targetDir)
+	if err := os.MkdirAll(parentDir, 0o755); err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp(parentDir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tempDir)
+		}
+	}()
+
+	// MkdirTemp creates the directory with 0o700.
+	perm := os.FileMode(0o755)
+	if fi, err := os.Stat(targetDir); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	if err := os.Chmod(tempDir, perm); err != nil {
+		return err
+	}
+
+	opts := a.opts
+	opts.Extract.Atomic = false
+	if onWrite := opts.Extract.OnWrite; onWrite != nil {
+		// Report the final paths.
+		opts.Extract.OnWrite = func(filename string, replaced bool) {
+			onWrite(filepath.Join(targetDir, strings.TrimPrefix(filename, tempDir)), replaced)
+		}
+	}
+
+	if err := extract(&archivist{archiver: a.archiver, typ: a.typ, opts: opts}, tempDir); err != nil {
+		return err
+	}
+
+	return replaceDir(tempDir, targetDir)
+}
+
+// replaceDir moves the directory src into place as dst, replacing any existing dst.
+func replaceDir(src, dst string) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+
+	parentDir, base := filepath.Split(dst)
+	oldDir, err := os.MkdirTemp(parentDir, "."+base+".old")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(oldDir); err != nil {
+		return err
+	}
+
+	if err := os.Rename(dst, oldDir); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		// Put the old directory back.
+		os.Rename(oldDir, dst)
+		return err
+	}
+
+	return os.RemoveAll(oldDir)
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestExtractAtomic(t *testing.T) {
+	c := qt.New(t)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		parentDir := t.TempDir()
+		targetDir := filepath.Join(parentDir, "target")
+		c.Assert(os.MkdirAll(targetDir, 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filepath.Join(targetDir, "old.txt"), []byte("old"), 0o644), qt.IsNil)
+
+		var written []string
+		a, err := NewWithOptions(tp, Options{Extract: ExtractOptions{
+			Atomic:  true,
+			OnWrite: func(filename string, replaced bool) { written = append(written, filename) },
+		}})
+		c.Assert(err, qt.IsNil)
+
+		assertOnlyTarget := func() {
+			entries, err := os.ReadDir(parentDir)
+			c.Assert(err, qt.IsNil)
+			c.Assert(entries, qt.HasLen, 1)
+			c.Assert(entries[0].Name(), qt.Equals, "target")
+		}
+
+		// A failed extraction leaves the target untouched.
+		err = a.Extract(newTestArchive(c, tp,
+			testEntry{name: "new.txt", content: "new"},
+			testEntry{name: "../evil.txt", content: "evil"},
+		), targetDir)
+		var unsafeErr *UnsafePathError
+		c.Assert(errors.As(err, &unsafeErr), qt.IsTrue)
+		assertOnlyTarget()
+		b, err := os.ReadFile(filepath.Join(targetDir, "old.txt"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "old")
+		_, err = os.Stat(filepath.Join(targetDir, "new.txt"))
+		c.Assert(os.IsNotExist(err), qt.IsTrue)
+
+		// A successful extraction replaces the target.
+		written = nil
+		c.Assert(a.Extract(newTestArchive(c, tp,
+			testEntry{name: "new.txt", content: "new"},
+			testEntry{name: "sub/new2.txt", content: "new2"},
+		), targetDir), qt.IsNil)
+		assertOnlyTarget()
+		_, err = os.Stat(filepath.Join(targetDir, "old.txt"))
+		c.Assert(os.IsNotExist(err), qt.IsTrue)
+		b, err = os.ReadFile(filepath.Join(targetDir, "sub", "new2.txt"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "new2")
+		c.Assert(written, qt.DeepEquals, []string{filepath.Join(targetDir, "new.txt"), filepath.Join(targetDir, "sub", "new2.txt")})
+
+		if runtime.GOOS != "windows" {
+			fi, err := os.Stat(targetDir)
+			c.Assert(err, qt.IsNil)
+			c.Assert(fi.Mode().Perm(), qt.Equals, os.FileMode(0o755))
+		}
+
+		// A target that does not exist.
+		newTargetDir := filepath.Join(parentDir, "a", "b")
+		c.Assert(a.Extract(newTestArchive(c, tp, testEntry{name: "new.txt", content: "new"}), newTargetDir), qt.IsNil)
+		_, err = os.Stat(filepath.Join(newTargetDir, "new.txt"))
+		c.Assert(err, qt.IsNil)
+	}
+
+	// The target directory is replaced as a whole, so conflicts can not be handled.
+	for _, onConflict := range []ConflictPolicy{ConflictSkip, ConflictFail, ConflictNewer} {
+		_, err := NewWithOptions(TypeTarGz, Options{Extract: ExtractOptions{Atomic: true, OnConflict: onConflict}})
+		c.Assert(err, qt.ErrorMatches, "option Atomic can not be combined with OnConflict.*")
+	}
+}
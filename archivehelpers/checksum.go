@@ -0,0 +1,111 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned (wrapped in a ChecksumError) when a file's content
+// does not match the checksum recorded in the archive.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+const (
+	// paxSHA256 is the PAX record holding the hex encoded SHA-256 digest of a file in tar archives.
+	paxSHA256 = "HELPERS.sha256"
+
+	// zipCommentSHA256 prefixes the hex encoded SHA-256 digest of a file in the zip file comment.
+	zipCommentSHA256 = "sha256="
+)
+
+// ChecksumError is returned when ExtractOptions.VerifyChecksums is set and a file
+// does not match its recorded SHA-256 digest.
+type ChecksumError struct {
+	// Name is the name of the entry in the archive.
+	Name string
+
+	// Expected is the hex encoded digest recorded in the archive.
+	// It is empty if the archive has no digest for the entry.
+	Expected string
+
+	// Actual is the hex encoded digest of the extracted content.
+	Actual string
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("%s: no checksum recorded for %q", ErrChecksumMismatch, e.Name)
+	}
+	return fmt.Sprintf("%s for %q: expected sha256 %s, got %s", ErrChecksumMismatch, e.Name, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// fileChecksum returns the hex encoded SHA-256 digest of the content of src.
+func fileChecksum(src fileSource) (string, error) {
+	// The file is read again when added, which is what is reported as progress.
+	if ps, ok := src.(progressSource); ok {
+		src = ps.fileSource
+	}
+	f, err := src.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// headerChecksum returns the digest recorded for the given entry, if any.
+func headerChecksum(header *tar.Header) string {
+	return header.PAXRecords[paxSHA256]
+}
+
+// checksumFromZipComment returns the digest stored in a zip file comment, if any.
+func checksumFromZipComment(comment string) string {
+	s, found := strings.CutPrefix(comment, zipCommentSHA256)
+	if !found {
+		return ""
+	}
+	return s
+}
+
+// checksumReader computes the SHA-256 digest of everything read through it.
+type checksumReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{r: r, h: sha256.New()}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	return n, err
+}
+
+// verify checks the digest of the content read against the one recorded in header.
+func (r *checksumReader) verify(header *tar.Header) error {
+	expected := headerChecksum(header)
+	actual := hex.EncodeToString(r.h.Sum(nil))
+	if expected != actual {
+		return &ChecksumError{Name: header.Name, Expected: expected, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestChecksums(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "sub"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("hello"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "sub", "b.txt"), []byte("world"), 0o644), qt.IsNil)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		var last Progress
+		a, err := NewWithOptions(tp, Options{
+			Archive: ArchiveOptions{Checksums: true, IncludeDirectories: true, Progress: func(p Progress) { last = p }},
+			Extract: ExtractOptions{VerifyChecksums: true},
+		})
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		// Computing the checksums is not reported as progress.
+		c.Assert(last.BytesDone, qt.Equals, int64(len("hello")+len("world")))
+
+		targetDir := t.TempDir()
+		c.Assert(a.Extract(io.NopCloser(bytes.NewReader(buf.Bytes())), targetDir), qt.IsNil)
+		b, err := os.ReadFile(filepath.Join(targetDir, "sub", "b.txt"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "world")
+
+		// Archives without checksums are rejected.
+		err = a.Extract(newTestArchive(c, tp, testEntry{name: "a.txt", content: "hello"}), t.TempDir())
+		var checksumErr *ChecksumError
+		c.Assert(errors.As(err, &checksumErr), qt.IsTrue, qt.Commentf("%s: %v", tp, err))
+		c.Assert(checksumErr.Expected, qt.Equals, "")
+	}
+}
+
+func TestChecksumsMismatch(t *testing.T) {
+	c := qt.New(t)
+
+	const content = "hello"
+	sum := sha256.Sum256([]byte(content))
+	actual := hex.EncodeToString(sum[:])
+	expected := strings.Repeat("0", 64)
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	c.Assert(tw.WriteHeader(&tar.Header{
+		Name:       "a.txt",
+		Typeflag:   tar.TypeReg,
+		Mode:       0o644,
+		Size:       int64(len(content)),
+		PAXRecords: map[string]string{paxSHA256: expected},
+	}), qt.IsNil)
+	_, err := tw.Write([]byte(content))
+	c.Assert(err, qt.IsNil)
+	c.Assert(tw.Close(), qt.IsNil)
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "a.txt", Method: zip.Deflate, Comment: zipCommentSHA256 + expected})
+	c.Assert(err, qt.IsNil)
+	_, err = w.Write([]byte(content))
+	c.Assert(err, qt.IsNil)
+	c.Assert(zw.Close(), qt.IsNil)
+
+	for tp, archive := range map[Type][]byte{TypeTar: tarBuf.Bytes(), TypeZip: zipBuf.Bytes()} {
+		a, err := NewWithOptions(tp, Options{Extract: ExtractOptions{VerifyChecksums: true}})
+		c.Assert(err, qt.IsNil)
+
+		targetDir := t.TempDir()
+		err = a.Extract(io.NopCloser(bytes.NewReader(archive)), targetDir)
+		c.Assert(errors.Is(err, ErrChecksumMismatch), qt.IsTrue)
+		var checksumErr *ChecksumError
+		c.Assert(errors.As(err, &checksumErr), qt.IsTrue)
+		c.Assert(*checksumErr, qt.Equals, ChecksumError{Name: "a.txt", Expected: expected, Actual: actual})
+
+		// The corrupt file is removed.
+		_, err = os.Stat(filepath.Join(targetDir, "a.txt"))
+		c.Assert(os.IsNotExist(err), qt.IsTrue)
+
+		// Without verification the file is extracted as is.
+		a, err = New(tp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(a.Extract(io.NopCloser(bytes.NewReader(archive)), targetDir), qt.IsNil)
+	}
+}
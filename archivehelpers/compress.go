@@ -0,0 +1,162 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io"
+
+	"github.com/bep/helpers/parahelpers"
+)
+
+func (o ArchiveOptions) compressionLevel() int {
+	if o.NoCompression {
+		return flate.NoCompression
+	}
+	if o.CompressionLevel == 0 {
+		return flate.BestCompression
+	}
+	return o.CompressionLevel
+}
+
+// parallelGzipBlockSize is the amount of uncompressed data in each gzip member
+// written by parallelGzipWriter.
+const parallelGzipBlockSize = 1 << 20
+
+// parallelGzipWriter compresses blocks of its input in parallel,
+// writing each block as a separate gzip member in order.
+type parallelGzipWriter struct {
+	w       io.Writer
+	level   int
+	workers int
+	runner  parahelpers.Runner
+
+	buf     []byte
+	pending []*gzipBlock
+	err     error
+}
+
+type gzipBlock struct {
+	done chan struct{}
+	out  bytes.Buffer
+	err  error
+}
+
+func newParallelGzipWriter(w io.Writer, level, workers int) *parallelGzipWriter {
+	runner, _ := parahelpers.New(workers).Start(context.Background())
+	return &parallelGzipWriter{
+		w:       w,
+		level:   level,
+		workers: workers,
+		runner:  runner,
+		buf:     make([]byte, 0, parallelGzipBlockSize),
+	}
+}
+
+func (w *parallelGzipWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), parallelGzipBlockSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == parallelGzipBlockSize {
+			if err := w.flushBlock(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flushBlock starts compressing the current buffer and
+// writes any blocks that are done.
+func (w *parallelGzipWriter) flushBlock() error {
+	data := w.buf
+	w.buf = make([]byte, 0, parallelGzipBlockSize)
+
+	b := &gzipBlock{done: make(chan struct{})}
+	w.pending = append(w.pending, b)
+
+	// Note that errors are returned through the block
+	// so the runner never cancels any pending work.
+	w.runner.Run(func() error {
+		defer close(b.done)
+		gw, err := gzip.NewWriterLevel(&b.out, w.level)
+		if err != nil {
+			b.err = err
+			return nil
+		}
+		if _, err := gw.Write(data); err != nil {
+			b.err = err
+			return nil
+		}
+		b.err = gw.Close()
+		return nil
+	})
+
+	// Bound the memory used by limiting the number of blocks in flight.
+	return w.writeBlocks(len(w.pending) > 2*w.workers)
+}
+
+// writeBlocks writes the pending blocks that are done in order.
+// If wait is set, it waits for the first block.
+func (w *parallelGzipWriter) writeBlocks(wait bool) error {
+	for len(w.pending) > 0 {
+		b := w.pending[0]
+		if wait {
+			<-b.done
+			wait = false
+		} else {
+			select {
+			case <-b.done:
+			default:
+				return nil
+			}
+		}
+		w.pending = w.pending[1:]
+		if b.err != nil {
+			w.err = b.err
+			return w.err
+		}
+		if _, err := b.out.WriteTo(w.w); err != nil {
+			w.err = err
+			return w.err
+		}
+	}
+	return nil
+}
+
+func (w *parallelGzipWriter) Close() error {
+	err := w.close()
+	// Always wait for the blocks in flight, so no goroutines are left behind on errors.
+	if waitErr := w.runner.Wait(); err == nil {
+		err = waitErr
+	}
+	return err
+}
+
+func (w *parallelGzipWriter) close() error {
+	if w.err != nil {
+		return w.err
+	}
+	// Always write at least one member so the result is a valid gzip stream.
+	if len(w.buf) > 0 || len(w.pending) == 0 {
+		if err := w.flushBlock(); err != nil {
+			return err
+		}
+	}
+	for len(w.pending) > 0 {
+		if err := w.writeBlocks(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
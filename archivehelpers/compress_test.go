@@ -0,0 +1,126 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCompressionLevel(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte(strings.Repeat("abcdefgh", 10000)), 0o644), qt.IsNil)
+
+	for _, tp := range []Type{TypeTarGz, TypeZip} {
+		archive := func(level int) []byte {
+			a, err := NewWithOptions(tp, Options{Archive: ArchiveOptions{CompressionLevel: level}})
+			c.Assert(err, qt.IsNil)
+			var buf bytes.Buffer
+			c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+			return buf.Bytes()
+		}
+
+		best, huffman := archive(0), archive(flate.HuffmanOnly)
+		c.Assert(len(best) < len(huffman), qt.IsTrue, qt.Commentf("%s: %d %d", tp, len(best), len(huffman)))
+		c.Assert(archive(flate.BestCompression), qt.DeepEquals, best)
+		c.Assert(len(archive(flate.BestSpeed)) > 0, qt.IsTrue)
+
+		a, err := NewWithOptions(tp, Options{Archive: ArchiveOptions{NoCompression: true}})
+		c.Assert(err, qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		c.Assert(buf.Len() > 80000, qt.IsTrue, qt.Commentf("%s: %d", tp, buf.Len()))
+		c.Assert(a.Extract(io.NopCloser(&buf), t.TempDir()), qt.IsNil)
+
+		_, err = NewWithOptions(tp, Options{Archive: ArchiveOptions{CompressionLevel: 42}})
+		c.Assert(err, qt.ErrorMatches, "invalid compression level 42")
+	}
+}
+
+func TestParallelCompression(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	for i := range 5 {
+		var sb strings.Builder
+		for j := range 200000 {
+			fmt.Fprintf(&sb, "%d:%d\n", i, j)
+		}
+		c.Assert(os.WriteFile(filepath.Join(sourceDir, fmt.Sprintf("file%d.txt", i)), []byte(sb.String()), 0o644), qt.IsNil)
+	}
+
+	a, err := NewWithOptions(TypeTarGz, Options{Archive: ArchiveOptions{CompressionWorkers: 4, CompressionLevel: flate.BestSpeed}})
+	c.Assert(err, qt.IsNil)
+	var buf bytes.Buffer
+	c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+	archive := buf.Bytes()
+
+	// A multi-member gzip stream.
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	c.Assert(err, qt.IsNil)
+	gzr.Multistream(false)
+	first, err := io.Copy(io.Discard, gzr)
+	c.Assert(err, qt.IsNil)
+	c.Assert(first, qt.Equals, int64(parallelGzipBlockSize))
+
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(bytes.NewReader(archive)), targetDir), qt.IsNil)
+	for i := range 5 {
+		name := fmt.Sprintf("file%d.txt", i)
+		b1, err := os.ReadFile(filepath.Join(sourceDir, name))
+		c.Assert(err, qt.IsNil)
+		b2, err := os.ReadFile(filepath.Join(targetDir, name))
+		c.Assert(err, qt.IsNil)
+		c.Assert(bytes.Equal(b1, b2), qt.IsTrue)
+	}
+
+	// Empty input must still be valid gzip.
+	var empty bytes.Buffer
+	w := newParallelGzipWriter(&empty, flate.BestSpeed, 2)
+	c.Assert(w.Close(), qt.IsNil)
+	gzr, err = gzip.NewReader(&empty)
+	c.Assert(err, qt.IsNil)
+	b, err := io.ReadAll(gzr)
+	c.Assert(err, qt.IsNil)
+	c.Assert(b, qt.HasLen, 0)
+}
+
+func TestParallelCompressionCloseWaits(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	w := newParallelGzipWriter(&buf, flate.BestCompression, 4)
+	data := bytes.Repeat([]byte("abcdefgh"), parallelGzipBlockSize/8)
+	for range 4 {
+		_, err := w.Write(data)
+		c.Assert(err, qt.IsNil)
+	}
+	pending := w.pending
+	c.Assert(pending, qt.Not(qt.HasLen), 0)
+
+	// Simulate a failed write with blocks still in flight.
+	w.err = errors.New("write failed")
+	c.Assert(w.Close(), qt.ErrorMatches, "write failed")
+
+	// All blocks in flight must be done when Close returns.
+	for _, b := range pending {
+		select {
+		case <-b.done:
+		default:
+			c.Fatal("block still in flight after Close")
+		}
+	}
+}
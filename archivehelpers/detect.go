@@ -0,0 +1,75 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sniffLen is the number of leading bytes DetectType looks at.
+const sniffLen = 512
+
+// ParseType parses s, e.g. "tar.gz", "tgz" or "zip", into a Type.
+func ParseType(s string) (Type, error) {
+	s = strings.ToLower(strings.TrimPrefix(s, "."))
+	for _, ti := range typeInfos() {
+		if ti.name == s {
+			return ti.typ, nil
+		}
+		for _, ext := range ti.extensions {
+			if ext[1:] == s {
+				return ti.typ, nil
+			}
+		}
+	}
+	return TypeUnknown, fmt.Errorf("unknown archive type %q", s)
+}
+
+// TypeFromFilename returns the Type for the given filename based on its extension,
+// e.g. ".tar.gz", ".tgz", ".tar" or ".zip".
+// It returns TypeUnknown if the extension is not recognized.
+func TypeFromFilename(filename string) Type {
+	filename = strings.ToLower(filename)
+	typ, longest := TypeUnknown, 0
+	for _, ti := range typeInfos() {
+		for _, ext := range ti.extensions {
+			if len(ext) > longest && strings.HasSuffix(filename, ext) {
+				typ, longest = ti.typ, len(ext)
+			}
+		}
+	}
+	return typ
+}
+
+// DetectType detects the Type of the archive in r by looking at its leading bytes.
+// It returns the Type (TypeUnknown if not recognized) and a reader that
+// will return the full content of r, including the bytes read for detection.
+func DetectType(r io.Reader) (Type, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return TypeUnknown, nil, err
+	}
+	head = head[:n]
+
+	return detectType(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+func detectType(head []byte) Type {
+	for _, ti := range typeInfos() {
+		if len(head) < ti.magicOffset {
+			continue
+		}
+		for _, magic := range ti.magic {
+			if bytes.HasPrefix(head[ti.magicOffset:], magic) {
+				return ti.typ
+			}
+		}
+	}
+	return TypeUnknown
+}
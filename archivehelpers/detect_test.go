@@ -0,0 +1,71 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDetectType(t *testing.T) {
+	c := qt.New(t)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		typ, r, err := DetectType(newTestArchive(c, tp, testEntry{name: "a.txt", content: "hello"}))
+		c.Assert(err, qt.IsNil)
+		c.Assert(typ, qt.Equals, tp)
+
+		// The returned reader must not have lost the sniffed bytes.
+		a, err := New(typ)
+		c.Assert(err, qt.IsNil)
+		targetDir := t.TempDir()
+		c.Assert(a.Extract(io.NopCloser(r), targetDir), qt.IsNil)
+		b, err := os.ReadFile(filepath.Join(targetDir, "a.txt"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "hello")
+	}
+
+	typ, r, err := DetectType(strings.NewReader("foo"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(typ, qt.Equals, TypeUnknown)
+	b, err := io.ReadAll(r)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "foo")
+}
+
+func TestTypeFromFilename(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(TypeFromFilename("foo.tar.gz"), qt.Equals, TypeTarGz)
+	c.Assert(TypeFromFilename("/a/b/FOO.TGZ"), qt.Equals, TypeTarGz)
+	c.Assert(TypeFromFilename("foo.zip"), qt.Equals, TypeZip)
+	c.Assert(TypeFromFilename("foo.tar"), qt.Equals, TypeTar)
+	c.Assert(TypeFromFilename("foo.tar.bz2"), qt.Equals, TypeTarBz2)
+	c.Assert(TypeFromFilename("foo.tbz2"), qt.Equals, TypeTarBz2)
+	c.Assert(TypeFromFilename("foo.gz"), qt.Equals, TypeUnknown)
+	c.Assert(TypeFromFilename("foo"), qt.Equals, TypeUnknown)
+}
+
+func TestParseType(t *testing.T) {
+	c := qt.New(t)
+
+	for _, s := range []string{"tar.gz", "TGZ", ".tar.gz"} {
+		typ, err := ParseType(s)
+		c.Assert(err, qt.IsNil)
+		c.Assert(typ, qt.Equals, TypeTarGz)
+	}
+	typ, err := ParseType("zip")
+	c.Assert(err, qt.IsNil)
+	c.Assert(typ, qt.Equals, TypeZip)
+	typ, err = ParseType("tar.bz2")
+	c.Assert(err, qt.IsNil)
+	c.Assert(typ, qt.Equals, TypeTarBz2)
+	_, err = ParseType("rar")
+	c.Assert(err, qt.IsNotNil)
+}
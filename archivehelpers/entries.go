@@ -0,0 +1,122 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"iter"
+	"strings"
+	"time"
+)
+
+// EntryType is the type of an archive entry.
+type EntryType int
+
+const (
+	// EntryTypeOther is any entry type not covered below, e.g. a device file.
+	EntryTypeOther EntryType = iota
+	// EntryTypeFile is a regular file.
+	EntryTypeFile
+	// EntryTypeDir is a directory.
+	EntryTypeDir
+	// EntryTypeSymlink is a symbolic link.
+	EntryTypeSymlink
+	// EntryTypeHardlink is a hard link to another entry in the archive.
+	EntryTypeHardlink
+)
+
+func (t EntryType) String() string {
+	switch t {
+	case EntryTypeFile:
+		return "file"
+	case EntryTypeDir:
+		return "dir"
+	case EntryTypeSymlink:
+		return "symlink"
+	case EntryTypeHardlink:
+		return "hardlink"
+	default:
+		return "other"
+	}
+}
+
+// Entry describes an entry in an archive.
+type Entry struct {
+	// Name is the slash separated path of the entry in the archive.
+	// Directory names have no trailing slash.
+	Name string
+
+	// Type is the entry type.
+	Type EntryType
+
+	// Size is the uncompressed size in bytes, zero for anything but regular files.
+	Size int64
+
+	// Mode holds the permission and type bits.
+	Mode fs.FileMode
+
+	// ModTime is the modification time.
+	ModTime time.Time
+
+	// Linkname is the link target for symlinks and hard links.
+	Linkname string
+}
+
+func entryFromHeader(h *tar.Header) Entry {
+	e := Entry{
+		Name:     strings.TrimSuffix(h.Name, "/"),
+		Size:     h.Size,
+		Mode:     h.FileInfo().Mode(),
+		ModTime:  h.ModTime,
+		Linkname: h.Linkname,
+	}
+
+	switch h.Typeflag {
+	case tar.TypeReg:
+		e.Type = EntryTypeFile
+	case tar.TypeDir:
+		e.Type = EntryTypeDir
+	case tar.TypeSymlink:
+		e.Type = EntryTypeSymlink
+	case tar.TypeLink:
+		e.Type = EntryTypeHardlink
+	default:
+		e.Type = EntryTypeOther
+	}
+
+	if e.Type != EntryTypeFile {
+		e.Size = 0
+	}
+
+	return e
+}
+
+func (a *archivist) Entries(in io.ReadCloser) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		defer in.Close()
+
+		r, err := a.archiver.NewEntryReader(in)
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+		defer r.Close()
+
+		for {
+			header, _, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Entry{}, err)
+				return
+			}
+			if !yield(entryFromHeader(header), nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestEntries(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "sub"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "config.toml"), []byte("title = 'foo'"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "sub", "file.txt"), []byte("hello"), 0o600), qt.IsNil)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		archive := buf.Bytes()
+
+		var names []string
+		var total int64
+		for e, err := range a.Entries(io.NopCloser(bytes.NewReader(archive))) {
+			c.Assert(err, qt.IsNil)
+			c.Assert(e.Type, qt.Equals, EntryTypeFile)
+			names = append(names, e.Name)
+			total += e.Size
+			if e.Name == "sub/file.txt" {
+				c.Assert(e.Mode.Perm(), qt.Equals, os.FileMode(0o600))
+				c.Assert(e.ModTime.IsZero(), qt.IsFalse)
+			}
+		}
+		c.Assert(names, qt.DeepEquals, []string{"config.toml", "sub/file.txt"})
+		c.Assert(total, qt.Equals, int64(len("title = 'foo'")+len("hello")))
+
+		// Stop early.
+		var count int
+		for range a.Entries(io.NopCloser(bytes.NewReader(archive))) {
+			count++
+			break
+		}
+		c.Assert(count, qt.Equals, 1)
+	}
+
+	a, _ := New(TypeTarGz)
+	var entries []Entry
+	for e, err := range a.Entries(newTestArchive(c, TypeTarGz,
+		testEntry{name: "dir/", typeflag: tar.TypeDir},
+		testEntry{name: "dir/link", typeflag: tar.TypeSymlink, linkname: "../file.txt"},
+		testEntry{name: "hardlink", typeflag: tar.TypeLink, linkname: "dir/link"},
+	)) {
+		c.Assert(err, qt.IsNil)
+		entries = append(entries, e)
+	}
+	c.Assert(entries, qt.HasLen, 3)
+	c.Assert(entries[0].Name, qt.Equals, "dir")
+	c.Assert(entries[0].Type, qt.Equals, EntryTypeDir)
+	c.Assert(entries[1].Type, qt.Equals, EntryTypeSymlink)
+	c.Assert(entries[1].Linkname, qt.Equals, "../file.txt")
+	c.Assert(entries[2].Type, qt.Equals, EntryTypeHardlink)
+	c.Assert(entries[2].Type.String(), qt.Equals, "hardlink")
+
+	var gotErr error
+	for _, err := range a.Entries(io.NopCloser(strings.NewReader("not an archive"))) {
+		gotErr = err
+	}
+	c.Assert(gotErr, qt.IsNotNil)
+	c.Assert(errors.Is(gotErr, io.EOF), qt.IsFalse)
+}
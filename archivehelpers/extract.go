@@ -0,0 +1,584 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnsafePath is returned (wrapped in an UnsafePathError) when an archive entry
+	// would be written outside of the target directory.
+	ErrUnsafePath = errors.New("unsafe path")
+
+	// ErrUnsupportedEntryType is returned (wrapped) when an archive entry
+	// is of a type that can not be extracted, e.g. a device or a FIFO.
+	ErrUnsupportedEntryType = errors.New("unsupported entry type")
+)
+
+// ExtractError records the archive entry and the operation that failed during Extract.
+// The cause, e.g. an UnsafePathError, a LimitError or an *fs.PathError, is available via errors.Is and errors.As.
+type ExtractError struct {
+	// Name is the name of the archive entry.
+	Name string
+
+	// Op is the operation that failed, one of "check", "mkdir", "write", "symlink", "link", "metadata" and "whiteout".
+	Op string
+
+	// Err is the cause.
+	Err error
+}
+
+func (e *ExtractError) Error() string {
+	return fmt.Sprintf("%s %q: %v", e.Op, e.Name, e.Err)
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// UnsafePathError is returned by Extract when an archive entry would be
+// written outside of the target directory.
+// It wraps ErrUnsafePath.
+type UnsafePathError struct {
+	// Name is the name of the archive entry.
+	Name string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("archive entry %q resolves outside of the target directory", e.Name)
+}
+
+func (e *UnsafePathError) Unwrap() error {
+	return ErrUnsafePath
+}
+
+// ConflictPolicy decides what Extract does when a file already exists.
+type ConflictPolicy int
+
+const (
+	// ConflictOverwrite overwrites existing files. This is the default.
+	ConflictOverwrite ConflictPolicy = iota
+	// ConflictSkip keeps existing files.
+	ConflictSkip
+	// ConflictFail fails with a ConflictError.
+	ConflictFail
+	// ConflictNewer overwrites existing files only if the archive entry is newer.
+	ConflictNewer
+)
+
+// ConflictError is returned by Extract when a file already exists and
+// the conflict policy is ConflictFail.
+// It wraps fs.ErrExist.
+type ConflictError struct {
+	// Name is the name of the archive entry.
+	Name string
+
+	// Path is the path of the existing file.
+	Path string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("archive entry %q: %q already exists", e.Name, e.Path)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return fs.ErrExist
+}
+
+// extraction holds the state of a single Extract call.
+type extraction struct {
+	targetDir string
+
+	// targetDir with any symlinks resolved.
+	realTargetDir string
+
+	ctx  context.Context
+	r    entryReader
+	opts ExtractOptions
+
+	entries     int
+	entriesDone int
+	totalSize   int64
+
+	// Files and directories created, in order.
+	created []string
+
+	// Directories to restore the metadata for when done.
+	dirs []dirHeader
+
+	// Errors collected with ExtractOptions.ContinueOnError.
+	errs []error
+
+	// Whether to apply whiteout entries, see ExtractChain.
+	whiteouts bool
+}
+
+type dirHeader struct {
+	target string
+	header *tar.Header
+}
+
+func newExtraction(ctx context.Context, targetDir string, r entryReader, opts ExtractOptions) (*extraction, error) {
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		return nil, err
+	}
+	targetDir, err := filepath.Abs(targetDir)
+	if err != nil {
+		return nil, err
+	}
+	realTargetDir, err := filepath.EvalSymlinks(targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &extraction{
+		targetDir:     targetDir,
+		realTargetDir: realTargetDir,
+		ctx:           ctx,
+		r:             r,
+		opts:          opts,
+	}, nil
+}
+
+func (x *extraction) extractEntry(header *tar.Header, r io.Reader) error {
+	if op, err := x.writeEntry(header, r); err != nil {
+		return &ExtractError{Name: header.Name, Op: op, Err: err}
+	}
+	return nil
+}
+
+// writeEntry writes a single entry to disk.
+// On failure it returns the name of the operation that failed, see ExtractError.
+func (x *extraction) writeEntry(header *tar.Header, r io.Reader) (string, error) {
+	x.entries++
+	if err := x.checkEntry(header.Name, entrySize(header)); err != nil {
+		return "check", err
+	}
+
+	if x.whiteouts {
+		if name, ok := whiteoutTarget(header.Name); ok {
+			return "whiteout", x.whiteout(name)
+		}
+	}
+
+	target, err := x.resolve(header.Name)
+	if err != nil {
+		return "check", err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if !x.restoresDirectoryMetadata() {
+			return "mkdir", x.mkdirAll(target, x.perm(header))
+		}
+		// Restore the metadata when the directory content is written.
+		x.dirs = append(x.dirs, dirHeader{target: target, header: header})
+		return "mkdir", x.mkdirAll(target, 0o755)
+	case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+	default:
+		return "check", fmt.Errorf("%w: %q", ErrUnsupportedEntryType, header.Typeflag)
+	}
+
+	exists, write, err := x.checkConflict(target, header)
+	if err != nil || !write {
+		return "check", err
+	}
+
+	var op string
+	switch header.Typeflag {
+	case tar.TypeReg:
+		op, err = "write", x.writeRegular(target, header, r)
+	case tar.TypeSymlink:
+		op, err = "symlink", x.writeSymlink(target, header)
+	case tar.TypeLink:
+		op, err = "link", x.writeHardlink(target, header)
+	}
+	if err != nil {
+		return op, err
+	}
+
+	if !exists {
+		x.created = append(x.created, target)
+	}
+	if err := x.restoreMetadata(target, header); err != nil {
+		return "metadata", err
+	}
+	if x.opts.OnWrite != nil {
+		x.opts.OnWrite(target, exists)
+	}
+
+	return "", nil
+}
+
+// handleError returns err if extraction should stop.
+// With ExtractOptions.ContinueOnError, entry errors are collected and nil is returned,
+// but cancellation and exceeded limits always stop the extraction.
+func (x *extraction) handleError(err error) error {
+	if !x.opts.ContinueOnError || x.ctx.Err() != nil || errors.Is(err, ErrLimitExceeded) {
+		return x.fail(err)
+	}
+	x.errs = append(x.errs, err)
+	return nil
+}
+
+// fail returns err joined with the entry errors collected so far with ContinueOnError,
+// so they are not lost when the extraction stops.
+func (x *extraction) fail(err error) error {
+	if len(x.errs) == 0 {
+		return err
+	}
+	return errors.Join(append(x.errs, err)...)
+}
+
+// checkConflict applies the conflict policy if target already exists.
+// It reports whether target exists and whether the entry should be written.
+func (x *extraction) checkConflict(target string, header *tar.Header) (exists, write bool, err error) {
+	fi, err := os.Lstat(target)
+	if err != nil {
+		return false, true, nil
+	}
+
+	switch x.opts.OnConflict {
+	case ConflictSkip:
+		return true, false, nil
+	case ConflictFail:
+		return true, false, &ConflictError{Name: header.Name, Path: target}
+	case ConflictNewer:
+		return true, header.ModTime.After(fi.ModTime()), nil
+	default:
+		return true, true, nil
+	}
+}
+
+// mapHeader applies StripComponents, Filter and Rename to the header.
+// It returns false if the entry should be skipped.
+func (x *extraction) mapHeader(header *tar.Header) (*tar.Header, bool) {
+	if x.opts.StripComponents == 0 && x.opts.Filter == nil && x.opts.Rename == nil {
+		return header, true
+	}
+
+	h := *header
+	var ok bool
+	if h.Name, ok = x.mapName(h.Name); !ok {
+		return nil, false
+	}
+	if x.opts.Filter != nil && !x.opts.Filter(entryFromHeader(&h)) {
+		return nil, false
+	}
+	if x.opts.Rename != nil {
+		if h.Name, ok = x.opts.Rename(h.Name); !ok {
+			return nil, false
+		}
+	}
+
+	if h.Typeflag == tar.TypeLink {
+		if h.Linkname, ok = x.mapName(h.Linkname); !ok {
+			return nil, false
+		}
+		if x.opts.Rename != nil {
+			if h.Linkname, ok = x.opts.Rename(h.Linkname); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return &h, true
+}
+
+// mapName applies StripComponents to name.
+func (x *extraction) mapName(name string) (string, bool) {
+	if x.opts.StripComponents <= 0 {
+		return name, true
+	}
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	if len(parts) <= x.opts.StripComponents {
+		return "", false
+	}
+	return strings.Join(parts[x.opts.StripComponents:], "/"), true
+}
+
+// resolve returns the file system path for the archive entry with the given name.
+// It fails with an UnsafePathError if that path is outside of the target directory,
+// either lexically or through a symlink on disk.
+func (x *extraction) resolve(name string) (string, error) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return "", &UnsafePathError{Name: name}
+	}
+	target := filepath.Join(x.targetDir, rel)
+
+	// Directories that do not exist yet will be created as real directories,
+	// so we only need to check the closest ancestor that exists.
+	dir := filepath.Dir(target)
+	for len(dir) > len(x.targetDir) {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(x.realTargetDir, realDir) {
+		return "", &UnsafePathError{Name: name}
+	}
+
+	return target, nil
+}
+
+// writeRegular writes the content of a regular file entry to target,
+// verifying its checksum if ExtractOptions.VerifyChecksums is set.
+func (x *extraction) writeRegular(target string, header *tar.Header, r io.Reader) error {
+	r = &limitedReader{x: x, name: header.Name, r: r}
+	if !x.opts.VerifyChecksums {
+		return x.writeFile(target, r, x.perm(header))
+	}
+
+	cr := newChecksumReader(r)
+	if err := x.writeFile(target, cr, x.perm(header)); err != nil {
+		return err
+	}
+	if err := cr.verify(header); err != nil {
+		os.Remove(target)
+		return err
+	}
+	return nil
+}
+
+func (x *extraction) writeFile(target string, r io.Reader, perm os.FileMode) error {
+	if err := x.mkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	// Never write through an existing symlink.
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (x *extraction) writeSymlink(target string, header *tar.Header) error {
+	// The link target must be relative and resolve to somewhere inside the target directory.
+	// A ".." after any other path element could step out of a symlinked directory,
+	// which a lexical check can not catch, so only leading ".." elements are allowed.
+	linkname := filepath.FromSlash(header.Linkname)
+	if filepath.IsAbs(linkname) || hasInnerDotDot(linkname) || !filepath.IsLocal(filepath.Join(filepath.Dir(filepath.FromSlash(header.Name)), linkname)) {
+		return &UnsafePathError{Name: header.Name}
+	}
+
+	if err := x.prepareLink(target); err != nil {
+		return err
+	}
+
+	// The parent directory may be, or be below, a symlink extracted earlier,
+	// so check the link target relative to where the parent really is.
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(realParent, linkname)
+	if !isWithin(x.realTargetDir, realParent) || !isWithin(x.realTargetDir, dest) {
+		return &UnsafePathError{Name: header.Name}
+	}
+
+	if err := os.Symlink(linkname, target); err != nil {
+		return err
+	}
+
+	// Make sure any existing symlinks along the way do not take it outside.
+	// For links to files that do not exist (yet), check the closest ancestor that exists.
+	realDest, err := filepath.EvalSymlinks(target)
+	if os.IsNotExist(err) {
+		dir := filepath.Dir(dest)
+		for len(dir) > len(x.realTargetDir) {
+			if _, err := os.Lstat(dir); err == nil {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+		realDest, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+	if !isWithin(x.realTargetDir, realDest) {
+		os.Remove(target)
+		return &UnsafePathError{Name: header.Name}
+	}
+
+	return nil
+}
+
+// hasInnerDotDot reports whether the relative path p has a ".." element after any other element.
+func hasInnerDotDot(p string) bool {
+	leading := true
+	for _, elem := range strings.Split(p, string(filepath.Separator)) {
+		switch {
+		case elem == ".." && !leading:
+			return true
+		case elem != ".." && elem != "." && elem != "":
+			leading = false
+		}
+	}
+	return false
+}
+
+func (x *extraction) writeHardlink(target string, header *tar.Header) error {
+	linkTarget, err := x.resolve(header.Linkname)
+	if err != nil {
+		return &UnsafePathError{Name: header.Name}
+	}
+	if err := x.prepareLink(target); err != nil {
+		return err
+	}
+	return os.Link(linkTarget, target)
+}
+
+// prepareLink makes sure that a link can be created at target.
+func (x *extraction) prepareLink(target string) error {
+	if err := x.mkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if fi, err := os.Lstat(target); err == nil && !fi.IsDir() {
+		return os.Remove(target)
+	}
+	return nil
+}
+
+// finish is called when all entries are extracted.
+func (x *extraction) finish() error {
+	// Deepest first, so setting the metadata on a directory does not
+	// touch the modification time of its parent.
+	slices.SortStableFunc(x.dirs, func(a, b dirHeader) int {
+		return len(b.target) - len(a.target)
+	})
+	for _, d := range x.dirs {
+		if err := x.restoreMetadata(d.target, d.header); err != nil {
+			if err := x.handleError(&ExtractError{Name: d.header.Name, Op: "metadata", Err: err}); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(x.errs...)
+}
+
+// perm returns the permissions to create the entry with.
+func (x *extraction) perm(header *tar.Header) os.FileMode {
+	return header.FileInfo().Mode().Perm() &^ x.opts.Umask
+}
+
+func (x *extraction) restoresDirectoryMetadata() bool {
+	return x.opts.RestoreDirectoryMetadata || x.opts.PreservePermissions || x.opts.PreserveTimes || x.opts.PreserveOwner || x.opts.RestoreXattrs
+}
+
+// restoreMetadata restores the ownership, extended attributes, permissions and times of target as configured.
+func (x *extraction) restoreMetadata(target string, header *tar.Header) error {
+	switch header.Typeflag {
+	case tar.TypeLink:
+		// Shares its metadata with the link target.
+		return nil
+	case tar.TypeSymlink:
+		if x.opts.PreserveOwner && os.Geteuid() == 0 {
+			return os.Lchown(target, header.Uid, header.Gid)
+		}
+		return nil
+	}
+
+	isDir := header.Typeflag == tar.TypeDir
+
+	// Note that chown may clear the setuid and setgid bits, so do that first.
+	if x.opts.PreserveOwner && os.Geteuid() == 0 {
+		if err := os.Lchown(target, header.Uid, header.Gid); err != nil {
+			return err
+		}
+	}
+	// Before chmod, as setting attributes may need write permission.
+	if x.opts.RestoreXattrs {
+		if err := writeXattrs(target, xattrsFromPAX(header.PAXRecords, x.opts.XattrNamespaces)); err != nil {
+			return err
+		}
+	}
+	// Directories are created with 0o755 when their metadata is restored later.
+	if x.opts.PreservePermissions || isDir {
+		if err := os.Chmod(target, x.perm(header)); err != nil {
+			return err
+		}
+	}
+	if x.opts.PreserveTimes || (isDir && x.opts.RestoreDirectoryMetadata) {
+		atime := header.AccessTime
+		if atime.IsZero() {
+			atime = header.ModTime
+		}
+		if err := os.Chtimes(target, atime, header.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mkdirAll is like os.MkdirAll, but keeps track of the directories it creates.
+func (x *extraction) mkdirAll(dir string, perm os.FileMode) error {
+	if fi, err := os.Stat(dir); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		// Let os.MkdirAll create a proper error.
+		return os.MkdirAll(dir, perm)
+	}
+
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := x.mkdirAll(parent, 0o755); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Mkdir(dir, perm); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	x.created = append(x.created, dir)
+
+	return nil
+}
+
+// removeCreated removes all files and directories created by this extraction.
+func (x *extraction) removeCreated() {
+	for i := len(x.created) - 1; i >= 0; i-- {
+		os.Remove(x.created[i])
+	}
+	x.created = nil
+}
+
+// isWithin reports whether filename is dir or inside dir.
+func isWithin(dir, filename string) bool {
+	rel, err := filepath.Rel(dir, filename)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,520 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+type testEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	content  string
+	modTime  time.Time
+}
+
+func newTestArchive(c *qt.C, typ Type, entries ...testEntry) io.ReadCloser {
+	c.Helper()
+	var buf bytes.Buffer
+
+	switch typ {
+	case TypeTarGz, TypeTar:
+		var w io.WriteCloser = nopWriteCloser{&buf}
+		if typ == TypeTarGz {
+			w = gzip.NewWriter(&buf)
+		}
+		tw := tar.NewWriter(w)
+		for _, e := range entries {
+			typeflag := e.typeflag
+			if typeflag == 0 {
+				typeflag = tar.TypeReg
+			}
+			c.Assert(tw.WriteHeader(&tar.Header{
+				Name:     e.name,
+				Typeflag: typeflag,
+				Linkname: e.linkname,
+				Mode:     0o644,
+				Size:     int64(len(e.content)),
+				ModTime:  e.modTime,
+			}), qt.IsNil)
+			_, err := tw.Write([]byte(e.content))
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(tw.Close(), qt.IsNil)
+		c.Assert(w.Close(), qt.IsNil)
+	case TypeZip:
+		zw := zip.NewWriter(&buf)
+		for _, e := range entries {
+			header := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: e.modTime}
+			content := e.content
+			switch e.typeflag {
+			case tar.TypeSymlink:
+				header.SetMode(os.ModeSymlink | 0o777)
+				content = e.linkname
+			case tar.TypeLink:
+				c.Fatal("zip does not support hard links")
+			}
+			w, err := zw.CreateHeader(header)
+			c.Assert(err, qt.IsNil)
+			_, err = w.Write([]byte(content))
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(zw.Close(), qt.IsNil)
+	default:
+		c.Fatalf("unsupported type %s", typ)
+	}
+
+	return io.NopCloser(&buf)
+}
+
+func TestExtractUnsafePaths(t *testing.T) {
+	c := qt.New(t)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		for _, name := range []string{"../evil.txt", "a/../../evil.txt", "/abs.txt"} {
+			tempDir := t.TempDir()
+			targetDir := filepath.Join(tempDir, "target")
+			err := a.Extract(newTestArchive(c, tp, testEntry{name: "ok.txt"}, testEntry{name: name, content: "evil"}), targetDir)
+			var unsafeErr *UnsafePathError
+			c.Assert(errors.As(err, &unsafeErr), qt.IsTrue, qt.Commentf("%s: %s: %v", tp, name, err))
+			c.Assert(unsafeErr.Name, qt.Equals, name)
+			_, err = os.Stat(filepath.Join(tempDir, "evil.txt"))
+			c.Assert(os.IsNotExist(err), qt.IsTrue)
+		}
+	}
+}
+
+func TestExtractUnsafePathThroughSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	c := qt.New(t)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		outsideDir := t.TempDir()
+		targetDir := t.TempDir()
+		c.Assert(os.Symlink(outsideDir, filepath.Join(targetDir, "link")), qt.IsNil)
+		c.Assert(os.Symlink(filepath.Join(outsideDir, "file.txt"), filepath.Join(targetDir, "file.txt")), qt.IsNil)
+
+		err = a.Extract(newTestArchive(c, tp, testEntry{name: "link/evil.txt", content: "evil"}), targetDir)
+		var unsafeErr *UnsafePathError
+		c.Assert(errors.As(err, &unsafeErr), qt.IsTrue)
+		c.Assert(unsafeErr.Name, qt.Equals, "link/evil.txt")
+
+		// Existing symlinks are replaced, not written through.
+		c.Assert(a.Extract(newTestArchive(c, tp, testEntry{name: "file.txt", content: "inside"}), targetDir), qt.IsNil)
+		b, err := os.ReadFile(filepath.Join(targetDir, "file.txt"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "inside")
+
+		entries, err := os.ReadDir(outsideDir)
+		c.Assert(err, qt.IsNil)
+		c.Assert(entries, qt.HasLen, 0)
+	}
+}
+
+func TestArchiveLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "sub"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "sub", "file.txt"), []byte("hello"), 0o644), qt.IsNil)
+	c.Assert(os.Symlink("sub/file.txt", filepath.Join(sourceDir, "filelink")), qt.IsNil)
+	c.Assert(os.Symlink("sub", filepath.Join(sourceDir, "dirlink")), qt.IsNil)
+	c.Assert(os.Link(filepath.Join(sourceDir, "sub", "file.txt"), filepath.Join(sourceDir, "hardlink.txt")), qt.IsNil)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		for i := range 2 {
+			var buf bytes.Buffer
+			if i == 0 {
+				c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+			} else {
+				c.Assert(a.ArchiveFS(os.DirFS(sourceDir), ".", func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+			}
+
+			targetDir := t.TempDir()
+			c.Assert(a.Extract(io.NopCloser(&buf), targetDir), qt.IsNil)
+
+			link, err := os.Readlink(filepath.Join(targetDir, "filelink"))
+			c.Assert(err, qt.IsNil)
+			c.Assert(link, qt.Equals, "sub/file.txt")
+			link, err = os.Readlink(filepath.Join(targetDir, "dirlink"))
+			c.Assert(err, qt.IsNil)
+			c.Assert(link, qt.Equals, "sub")
+
+			b, err := os.ReadFile(filepath.Join(targetDir, "hardlink.txt"))
+			c.Assert(err, qt.IsNil)
+			c.Assert(string(b), qt.Equals, "hello")
+
+			if tp == TypeTarGz {
+				fi1, err := os.Stat(filepath.Join(targetDir, "hardlink.txt"))
+				c.Assert(err, qt.IsNil)
+				fi2, err := os.Stat(filepath.Join(targetDir, "sub", "file.txt"))
+				c.Assert(err, qt.IsNil)
+				c.Assert(os.SameFile(fi1, fi2), qt.IsTrue)
+			}
+		}
+	}
+}
+
+func TestExtractUnsafeLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	c := qt.New(t)
+
+	for _, test := range []struct {
+		tp    Type
+		entry testEntry
+	}{
+		{TypeTarGz, testEntry{name: "a/link", typeflag: tar.TypeSymlink, linkname: "../../outside"}},
+		{TypeTarGz, testEntry{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+		{TypeTarGz, testEntry{name: "link", typeflag: tar.TypeLink, linkname: "../outside"}},
+		{TypeZip, testEntry{name: "link", typeflag: tar.TypeSymlink, linkname: "../outside"}},
+	} {
+		a, err := New(test.tp)
+		c.Assert(err, qt.IsNil)
+		err = a.Extract(newTestArchive(c, test.tp, test.entry), t.TempDir())
+		var unsafeErr *UnsafePathError
+		c.Assert(errors.As(err, &unsafeErr), qt.IsTrue, qt.Commentf("%v", err))
+		c.Assert(unsafeErr.Name, qt.Equals, test.entry.name)
+	}
+
+	// A symlink that escapes through another symlink.
+	a, _ := New(TypeTarGz)
+	err := a.Extract(newTestArchive(c, TypeTarGz,
+		testEntry{name: "self", typeflag: tar.TypeSymlink, linkname: "."},
+		testEntry{name: "escape", typeflag: tar.TypeSymlink, linkname: "self/.."},
+	), t.TempDir())
+	var unsafeErr *UnsafePathError
+	c.Assert(errors.As(err, &unsafeErr), qt.IsTrue, qt.Commentf("%v", err))
+
+	// A dangling symlink that escapes through a symlinked parent directory.
+	targetDir := t.TempDir()
+	err = a.Extract(newTestArchive(c, TypeTarGz,
+		testEntry{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+		testEntry{name: "d/l", typeflag: tar.TypeSymlink, linkname: "../nonexistent-outside"},
+	), targetDir)
+	c.Assert(errors.As(err, &unsafeErr), qt.IsTrue, qt.Commentf("%v", err))
+	_, err = os.Lstat(filepath.Join(targetDir, "l"))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+
+	// A ".." after a possibly symlinked element.
+	err = a.Extract(newTestArchive(c, TypeTarGz,
+		testEntry{name: "d/up", typeflag: tar.TypeSymlink, linkname: ".."},
+		testEntry{name: "d/l", typeflag: tar.TypeSymlink, linkname: "up/../nonexistent"},
+	), t.TempDir())
+	c.Assert(errors.As(err, &unsafeErr), qt.IsTrue, qt.Commentf("%v", err))
+
+	// Dangling links inside the target directory are fine.
+	err = a.Extract(newTestArchive(c, TypeTarGz,
+		testEntry{name: "d/l", typeflag: tar.TypeSymlink, linkname: "../nonexistent"},
+		testEntry{name: "d/m", typeflag: tar.TypeSymlink, linkname: "./sub/nonexistent"},
+	), t.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	// Writing through an extracted symlink.
+	err = a.Extract(newTestArchive(c, TypeTarGz,
+		testEntry{name: "dir", typeflag: tar.TypeSymlink, linkname: "."},
+		testEntry{name: "dir/dir/file.txt", content: "inside"},
+	), t.TempDir())
+	c.Assert(err, qt.IsNil)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestExtractStripFilterRename(t *testing.T) {
+	c := qt.New(t)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		newArchive := func() io.ReadCloser {
+			return newTestArchive(c, tp,
+				testEntry{name: "project-v1.2.3/README.md", content: "readme"},
+				testEntry{name: "project-v1.2.3/docs/index.md", content: "index"},
+				testEntry{name: "project-v1.2.3/docs/drafts/draft.md", content: "draft"},
+				testEntry{name: "project-v1.2.3/src/main.go", content: "main"},
+			)
+		}
+
+		extract := func(opts ExtractOptions) []string {
+			a, err := NewWithOptions(tp, Options{Extract: opts})
+			c.Assert(err, qt.IsNil)
+			targetDir := t.TempDir()
+			c.Assert(a.Extract(newArchive(), targetDir), qt.IsNil)
+			var files []string
+			c.Assert(filepath.WalkDir(targetDir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, _ := filepath.Rel(targetDir, path)
+				files = append(files, filepath.ToSlash(rel))
+				return nil
+			}), qt.IsNil)
+			return files
+		}
+
+		c.Assert(extract(ExtractOptions{StripComponents: 1}), qt.DeepEquals, []string{"README.md", "docs/drafts/draft.md", "docs/index.md", "src/main.go"})
+		c.Assert(extract(ExtractOptions{StripComponents: 2}), qt.DeepEquals, []string{"drafts/draft.md", "index.md", "main.go"})
+		c.Assert(extract(ExtractOptions{StripComponents: 5}), qt.HasLen, 0)
+
+		c.Assert(extract(ExtractOptions{
+			StripComponents: 1,
+			Filter: func(e Entry) bool {
+				return strings.HasPrefix(e.Name, "docs/")
+			},
+			Rename: func(name string) (string, bool) {
+				if strings.Contains(name, "drafts") {
+					return "", false
+				}
+				return "content/" + strings.TrimPrefix(name, "docs/"), true
+			},
+		}), qt.DeepEquals, []string{"content/index.md"})
+
+		// Renamed paths are still checked.
+		a, err := NewWithOptions(tp, Options{Extract: ExtractOptions{
+			Rename: func(name string) (string, bool) { return "../" + name, true },
+		}})
+		c.Assert(err, qt.IsNil)
+		err = a.Extract(newArchive(), t.TempDir())
+		var unsafeErr *UnsafePathError
+		c.Assert(errors.As(err, &unsafeErr), qt.IsTrue)
+	}
+}
+
+func TestExtractConflicts(t *testing.T) {
+	c := qt.New(t)
+
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now().Truncate(time.Second)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		for _, test := range []struct {
+			policy   ConflictPolicy
+			modTime  time.Time
+			expect   string
+			replaced bool
+		}{
+			{ConflictOverwrite, old, "new", true},
+			{ConflictSkip, old, "existing content", false},
+			{ConflictFail, old, "existing content", false},
+			{ConflictNewer, old, "existing content", false},
+			{ConflictNewer, now.Add(time.Hour), "new", true},
+		} {
+			targetDir := t.TempDir()
+			existing := filepath.Join(targetDir, "existing.txt")
+			c.Assert(os.WriteFile(existing, []byte("existing content"), 0o644), qt.IsNil)
+			c.Assert(os.Chtimes(existing, now, now), qt.IsNil)
+
+			written := make(map[string]bool)
+			a, err := NewWithOptions(tp, Options{Extract: ExtractOptions{
+				OnConflict: test.policy,
+				OnWrite: func(filename string, replaced bool) {
+					rel, _ := filepath.Rel(targetDir, filename)
+					written[rel] = replaced
+				},
+			}})
+			c.Assert(err, qt.IsNil)
+
+			err = a.Extract(newTestArchive(c, tp,
+				testEntry{name: "new.txt", content: "new", modTime: test.modTime},
+				testEntry{name: "existing.txt", content: "new", modTime: test.modTime},
+			), targetDir)
+
+			if test.policy == ConflictFail {
+				c.Assert(errors.Is(err, fs.ErrExist), qt.IsTrue)
+				var conflictErr *ConflictError
+				c.Assert(errors.As(err, &conflictErr), qt.IsTrue)
+				c.Assert(conflictErr.Name, qt.Equals, "existing.txt")
+			} else {
+				c.Assert(err, qt.IsNil)
+			}
+
+			b, err := os.ReadFile(existing)
+			c.Assert(err, qt.IsNil)
+			c.Assert(string(b), qt.Equals, test.expect, qt.Commentf("%s: %d", tp, test.policy))
+
+			expectWritten := map[string]bool{"new.txt": false}
+			if test.replaced {
+				expectWritten["existing.txt"] = true
+			}
+			c.Assert(written, qt.DeepEquals, expectWritten)
+		}
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	c := qt.New(t)
+
+	newArchive := func() io.ReadCloser {
+		return newTestArchive(c, TypeTar,
+			testEntry{name: "a.txt", content: "a"},
+			testEntry{name: "../evil.txt", content: "evil"},
+			testEntry{name: "fifo", typeflag: tar.TypeFifo},
+			testEntry{name: "b.txt", content: "b"},
+		)
+	}
+
+	a, err := New(TypeTar)
+	c.Assert(err, qt.IsNil)
+	err = a.Extract(newArchive(), t.TempDir())
+	c.Assert(errors.Is(err, ErrUnsafePath), qt.IsTrue)
+	var extractErr *ExtractError
+	c.Assert(errors.As(err, &extractErr), qt.IsTrue)
+	c.Assert(extractErr.Name, qt.Equals, "../evil.txt")
+	c.Assert(extractErr.Op, qt.Equals, "check")
+	var unsafeErr *UnsafePathError
+	c.Assert(errors.As(err, &unsafeErr), qt.IsTrue)
+
+	a, err = NewWithOptions(TypeTar, Options{Extract: ExtractOptions{ContinueOnError: true}})
+	c.Assert(err, qt.IsNil)
+	targetDir := t.TempDir()
+	err = a.Extract(newArchive(), targetDir)
+	c.Assert(errors.Is(err, ErrUnsafePath), qt.IsTrue)
+	c.Assert(errors.Is(err, ErrUnsupportedEntryType), qt.IsTrue)
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	c.Assert(errs, qt.HasLen, 2)
+	c.Assert(errs[1].(*ExtractError).Name, qt.Equals, "fifo")
+
+	// The good entries are extracted.
+	for _, name := range []string{"a.txt", "b.txt"} {
+		_, err := os.Stat(filepath.Join(targetDir, name))
+		c.Assert(err, qt.IsNil)
+	}
+
+	// Limits still stop the extraction.
+	a, err = NewWithOptions(TypeTar, Options{Extract: ExtractOptions{ContinueOnError: true, Limits: Limits{MaxEntries: 2}}})
+	c.Assert(err, qt.IsNil)
+	targetDir = t.TempDir()
+	err = a.Extract(newArchive(), targetDir)
+	c.Assert(errors.Is(err, ErrLimitExceeded), qt.IsTrue)
+	c.Assert(errors.Is(err, ErrUnsafePath), qt.IsTrue, qt.Commentf("the skipped entries are kept"))
+	_, err = os.Stat(filepath.Join(targetDir, "b.txt"))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+
+	// A truncated archive keeps the skipped entries too.
+	b, err := io.ReadAll(newArchive())
+	c.Assert(err, qt.IsNil)
+	a, err = NewWithOptions(TypeTar, Options{Extract: ExtractOptions{ContinueOnError: true}})
+	c.Assert(err, qt.IsNil)
+	err = a.Extract(io.NopCloser(bytes.NewReader(b[:4*512+100])), t.TempDir())
+	c.Assert(errors.Is(err, io.ErrUnexpectedEOF), qt.IsTrue, qt.Commentf("%v", err))
+	c.Assert(errors.Is(err, ErrUnsafePath), qt.IsTrue)
+}
+
+func TestExtractLongAndUnicodeNames(t *testing.T) {
+	c := qt.New(t)
+
+	long := strings.Repeat("d", 100)
+	names := []string{
+		// More than the 100 bytes of a ustar name and the 255 bytes of a ustar prefix and name.
+		long + "/" + long + "/" + long + "/" + strings.Repeat("f", 120) + ".txt",
+		"日本語/ファイル.txt",
+		"émoji-😀/naïve café.txt",
+	}
+
+	sourceDir := t.TempDir()
+	for _, name := range names {
+		filename := filepath.Join(sourceDir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(name), 0o644), qt.IsNil)
+	}
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+
+		var got []string
+		for e, err := range a.Entries(io.NopCloser(bytes.NewReader(buf.Bytes()))) {
+			c.Assert(err, qt.IsNil)
+			got = append(got, e.Name)
+		}
+		c.Assert(got, qt.ContentEquals, names)
+
+		targetDir := t.TempDir()
+		c.Assert(a.Extract(io.NopCloser(bytes.NewReader(buf.Bytes())), targetDir), qt.IsNil)
+		for _, name := range names {
+			b, err := os.ReadFile(filepath.Join(targetDir, filepath.FromSlash(name)))
+			c.Assert(err, qt.IsNil, qt.Commentf("%s: %s", tp, name))
+			c.Assert(string(b), qt.Equals, name)
+		}
+	}
+}
+
+func TestExtractPAXGlobalHeader(t *testing.T) {
+	c := qt.New(t)
+
+	// As written by git archive.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	c.Assert(tw.WriteHeader(&tar.Header{
+		Name:       "pax_global_header",
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: map[string]string{"comment": "8d4c1b2a0e5f3b6c7d8e9f0a1b2c3d4e5f6a7b8c"},
+	}), qt.IsNil)
+	c.Assert(tw.WriteHeader(&tar.Header{Name: "repo/README.md", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5}), qt.IsNil)
+	_, err := tw.Write([]byte("hello"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(tw.Close(), qt.IsNil)
+	archive := buf.Bytes()
+
+	a, err := New(TypeTar)
+	c.Assert(err, qt.IsNil)
+
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(bytes.NewReader(archive)), targetDir), qt.IsNil)
+	b, err := os.ReadFile(filepath.Join(targetDir, "repo", "README.md"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "hello")
+	_, err = os.Stat(filepath.Join(targetDir, "pax_global_header"))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+
+	var names []string
+	for e, err := range a.Entries(io.NopCloser(bytes.NewReader(archive))) {
+		c.Assert(err, qt.IsNil)
+		names = append(names, e.Name)
+	}
+	c.Assert(names, qt.DeepEquals, []string{"repo/README.md"})
+
+	fsys, err := NewFS(TypeTar, bytes.NewReader(archive), int64(len(archive)))
+	c.Assert(err, qt.IsNil)
+	b, err = fs.ReadFile(fsys, "repo/README.md")
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "hello")
+	_, err = fs.Stat(fsys, "pax_global_header")
+	c.Assert(errors.Is(err, fs.ErrNotExist), qt.IsTrue)
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// File is a file to add to an archive with ArchiveFiles.
+type File struct {
+	// Source is the path to the file on the OS file system.
+	// Either Source or FS and Name must be set.
+	Source string
+
+	// FS and Name, the slash separated path in FS, is the file to add if Source is not set.
+	// Symlinks are stored as links if FS implements fs.ReadLinkFS, else they are followed.
+	FS   fs.FS
+	Name string
+
+	// Path is the slash separated path of the file in the archive.
+	// If empty, the base name of Source or Name is used.
+	Path string
+
+	// Mode, if set, overrides the permission bits of the file.
+	Mode fs.FileMode
+}
+
+func (f File) source() (fileSource, fs.FileInfo, error) {
+	switch {
+	case f.Source != "":
+		info, err := os.Lstat(f.Source)
+		return osFile(f.Source), info, err
+	case f.FS != nil:
+		var info fs.FileInfo
+		var err error
+		if _, ok := f.FS.(fs.ReadLinkFS); ok {
+			info, err = fs.Lstat(f.FS, f.Name)
+		} else {
+			info, err = fs.Stat(f.FS, f.Name)
+		}
+		return fsFile{fsys: f.FS, name: f.Name}, info, err
+	default:
+		return nil, nil, errors.New("either Source or FS must be set")
+	}
+}
+
+func (f File) targetPath() (string, error) {
+	p := f.Path
+	if p == "" {
+		if f.Source != "" {
+			p = filepath.ToSlash(filepath.Base(f.Source))
+		} else {
+			p = path.Base(f.Name)
+		}
+	}
+	if !fs.ValidPath(p) || p == "." {
+		return "", fmt.Errorf("invalid archive path %q", p)
+	}
+	return p, nil
+}
+
+// modeFileInfo overrides the permission bits of a fs.FileInfo.
+type modeFileInfo struct {
+	fs.FileInfo
+	perm fs.FileMode
+}
+
+func (fi modeFileInfo) Mode() fs.FileMode {
+	return fi.FileInfo.Mode().Type() | fi.perm.Perm()
+}
+
+func (a *archivist) ArchiveFiles(files []File, out io.WriteCloser) (err error) {
+	archive, err := a.newArchiveAdder(out)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	defer func() {
+		closeErr := archive.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	p := &archiveProgress{ctx: context.Background(), fn: a.opts.Archive.Progress}
+	seen := make(map[string]bool)
+
+	for _, f := range files {
+		targetPath, err := f.targetPath()
+		if err != nil {
+			return err
+		}
+		if seen[targetPath] {
+			return fmt.Errorf("duplicate archive path %q", targetPath)
+		}
+		seen[targetPath] = true
+
+		src, info, err := f.source()
+		if err != nil {
+			return err
+		}
+		if f.Mode != 0 {
+			info = modeFileInfo{FileInfo: info, perm: f.Mode}
+		}
+
+		if err := p.add(archive, src, info, targetPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
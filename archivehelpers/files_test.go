@@ -0,0 +1,84 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestArchiveFiles(t *testing.T) {
+	c := qt.New(t)
+
+	buildDir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(buildDir, "hugo"), []byte("binary"), 0o600), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(buildDir, "LICENSE"), []byte("MIT"), 0o644), qt.IsNil)
+	docs := fstest.MapFS{
+		"content/docs/README.md": {Data: []byte("docs"), Mode: 0o644},
+	}
+
+	files := []File{
+		{Source: filepath.Join(buildDir, "hugo"), Path: "bin/hugo", Mode: 0o755},
+		{Source: filepath.Join(buildDir, "LICENSE")},
+		{FS: docs, Name: "content/docs/README.md", Path: "docs/README.md"},
+	}
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveFiles(files, nopWriteCloser{&buf}), qt.IsNil)
+
+		var entries []string
+		for e, err := range a.Entries(io.NopCloser(bytes.NewReader(buf.Bytes()))) {
+			c.Assert(err, qt.IsNil)
+			entries = append(entries, e.Name)
+			if e.Name == "bin/hugo" && runtime.GOOS != "windows" {
+				c.Assert(e.Mode.Perm(), qt.Equals, fs.FileMode(0o755))
+			}
+		}
+		c.Assert(entries, qt.DeepEquals, []string{"bin/hugo", "LICENSE", "docs/README.md"})
+
+		targetDir := t.TempDir()
+		c.Assert(a.Extract(io.NopCloser(bytes.NewReader(buf.Bytes())), targetDir), qt.IsNil)
+		for name, content := range map[string]string{"bin/hugo": "binary", "LICENSE": "MIT", "docs/README.md": "docs"} {
+			b, err := os.ReadFile(filepath.Join(targetDir, filepath.FromSlash(name)))
+			c.Assert(err, qt.IsNil)
+			c.Assert(string(b), qt.Equals, content)
+		}
+	}
+}
+
+func TestArchiveFilesErrors(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "a.txt")
+	c.Assert(os.WriteFile(filename, []byte("a"), 0o644), qt.IsNil)
+
+	a, err := New(TypeTarGz)
+	c.Assert(err, qt.IsNil)
+
+	for _, test := range []struct {
+		files []File
+		err   string
+	}{
+		{[]File{{Path: "a.txt"}}, "either Source or FS must be set"},
+		{[]File{{Source: filename, Path: "../a.txt"}}, `invalid archive path "../a.txt"`},
+		{[]File{{Source: filename, Path: "/a.txt"}}, `invalid archive path "/a.txt"`},
+		{[]File{{Source: filename}, {Source: filename}}, `duplicate archive path "a.txt"`},
+		{[]File{{Source: filepath.Join(dir, "missing.txt")}}, ".*missing.txt.*"},
+	} {
+		c.Assert(a.ArchiveFiles(test.files, nopWriteCloser{io.Discard}), qt.ErrorMatches, test.err)
+	}
+}
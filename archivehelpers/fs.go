@@ -0,0 +1,397 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NewFS returns a read-only fs.FS for the archive of the given type in r.
+// Uncompressed tar and zip archives are indexed once and their files are read on demand.
+// Other archive types can not be read randomly, so the archive is read once
+// and its content is held in memory.
+// The default Limits apply to the entries and the total size of the files.
+// Symlinks are followed as long as they point to somewhere inside the archive.
+func NewFS(typ Type, r io.ReaderAt, size int64) (fs.FS, error) {
+	a, err := newArchivist(typ, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		er       entryReader
+		tarIndex *io.SectionReader
+	)
+	if typ == TypeTar {
+		// Read the headers directly from r, skipping the content.
+		tarIndex = io.NewSectionReader(r, 0, size)
+		er = &tarEntryReader{tr: tar.NewReader(tarIndex), cr: &countingReader{}, closer: io.NopCloser(nil)}
+	} else {
+		er, err = a.archiver.NewEntryReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer er.Close()
+
+	fsys := &memFS{
+		nodes: map[string]*memNode{
+			".": {name: ".", mode: fs.ModeDir | 0o755},
+		},
+	}
+
+	// Reuse the limit checks from Extract.
+	x := &extraction{ctx: context.Background(), r: er, opts: a.opts.Extract}
+
+	for {
+		header, content, err := er.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		x.entries++
+		if err := x.checkEntry(header.Name, entrySize(header)); err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+
+		n := &memNode{
+			name:     path.Base(name),
+			mode:     header.FileInfo().Mode(),
+			modTime:  header.ModTime,
+			linkname: header.Linkname,
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg:
+			if err := n.setContent(x, er, tarIndex, header, content); err != nil {
+				return nil, err
+			}
+		case tar.TypeLink:
+			target, found := fsys.nodes[path.Clean(header.Linkname)]
+			if !found {
+				continue
+			}
+			n.mode = target.mode
+			n.size, n.data, n.zf = target.size, target.data, target.zf
+			n.linkname = ""
+		case tar.TypeDir, tar.TypeSymlink:
+		default:
+			continue
+		}
+
+		fsys.add(name, n)
+	}
+
+	return fsys, nil
+}
+
+// setContent sets the content of the regular file n from the current entry in er.
+// Files in zip archives are decompressed on demand, and if tarIndex is set,
+// the uncompressed tar archive er reads from, files are read from it on demand.
+// Anything else is read into memory.
+func (n *memNode) setContent(x *extraction, er entryReader, tarIndex *io.SectionReader, header *tar.Header, content io.Reader) error {
+	n.size = header.Size
+
+	if zr, ok := er.(*zipEntryReader); ok {
+		n.zf = zr.currentFile
+	} else if tarIndex != nil && !isSparse(header) {
+		offset, err := tarIndex.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		n.data = io.NewSectionReader(tarIndex, offset, header.Size)
+	}
+
+	if n.zf != nil || n.data != nil {
+		// The content is not read here, so check the declared size.
+		x.totalSize += header.Size
+		return x.checkSize(header.Name, header.Size)
+	}
+
+	b, err := io.ReadAll(&limitedReader{x: x, name: header.Name, r: content})
+	if err != nil {
+		return err
+	}
+	n.size = int64(len(b))
+	n.data = io.NewSectionReader(bytes.NewReader(b), 0, n.size)
+	return nil
+}
+
+// isSparse reports whether header is a sparse file, which is not stored contiguously.
+func isSparse(header *tar.Header) bool {
+	if header.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range header.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// memFS is an fs.FS over an index of the entries of an archive.
+type memFS struct {
+	nodes map[string]*memNode
+}
+
+func (fsys *memFS) add(name string, n *memNode) {
+	if existing, found := fsys.nodes[name]; found {
+		if existing.IsDir() && n.IsDir() {
+			existing.mode, existing.modTime = n.mode, n.modTime
+			return
+		}
+		n.children = existing.children
+		fsys.nodes[name] = n
+		return
+	}
+
+	fsys.nodes[name] = n
+
+	dir := path.Dir(name)
+	parent, found := fsys.nodes[dir]
+	if !found {
+		parent = &memNode{name: path.Base(dir), mode: fs.ModeDir | 0o755}
+		fsys.add(dir, parent)
+	}
+	parent.children = append(parent.children, name)
+}
+
+// maxSymlinks is the maximum number of symlinks followed when resolving a path.
+const maxSymlinks = 40
+
+func (fsys *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	n, err := fsys.resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if n.IsDir() {
+		d := &memDir{memNode: n}
+		for _, child := range n.children {
+			d.entries = append(d.entries, fs.FileInfoToDirEntry(fsys.nodes[child]))
+		}
+		slices.SortFunc(d.entries, func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+		return d, nil
+	}
+
+	if n.zf != nil {
+		rc, err := n.zf.Open()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &memZipFile{memNode: n, rc: rc}, nil
+	}
+
+	data := n.data
+	if data == nil {
+		data = io.NewSectionReader(bytes.NewReader(nil), 0, 0)
+	}
+	return &memFile{memNode: n, SectionReader: io.NewSectionReader(data, 0, n.size)}, nil
+}
+
+// resolve looks up name, following any symlinks along the way.
+func (fsys *memFS) resolve(name string) (*memNode, error) {
+	for range maxSymlinks {
+		n, found := fsys.nodes[name]
+		if found {
+			if n.mode.Type() != fs.ModeSymlink {
+				return n, nil
+			}
+			name = path.Join(path.Dir(name), n.linkname)
+			if !fs.ValidPath(name) || strings.HasPrefix(n.linkname, "/") {
+				return nil, fs.ErrNotExist
+			}
+			continue
+		}
+
+		// Look for a symlink in one of the parent directories.
+		resolved := false
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if d, found := fsys.nodes[dir]; found && d.mode.Type() == fs.ModeSymlink {
+				target := path.Join(path.Dir(dir), d.linkname)
+				if !fs.ValidPath(target) || strings.HasPrefix(d.linkname, "/") {
+					return nil, fs.ErrNotExist
+				}
+				name = path.Join(target, strings.TrimPrefix(name, dir+"/"))
+				resolved = true
+				break
+			}
+		}
+		if !resolved {
+			return nil, fs.ErrNotExist
+		}
+	}
+	return nil, errors.New("too many levels of symbolic links")
+}
+
+// memNode is a file or directory in a memFS.
+// It implements fs.FileInfo.
+type memNode struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	size     int64
+	data     *io.SectionReader // The content of regular files, unless zf is set.
+	zf       *zip.File         // The content of regular files in zip archives.
+	linkname string
+	children []string
+}
+
+func (n *memNode) Name() string       { return n.name }
+func (n *memNode) Size() int64        { return n.size }
+func (n *memNode) Mode() fs.FileMode  { return n.mode }
+func (n *memNode) ModTime() time.Time { return n.modTime }
+func (n *memNode) IsDir() bool        { return n.mode.IsDir() }
+func (n *memNode) Sys() any           { return nil }
+
+type memFile struct {
+	*memNode
+	*io.SectionReader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.memNode, nil }
+func (f *memFile) Close() error               { return nil }
+
+// Size resolves the ambiguity between the embedded types.
+func (f *memFile) Size() int64 { return f.memNode.Size() }
+
+// memZipFile is a regular file in a zip archive, decompressed as it is read.
+// It implements io.Seeker and io.ReaderAt, as needed by e.g. http.FS,
+// by decompressing the file again from the start when needed.
+type memZipFile struct {
+	*memNode
+	rc    io.ReadCloser // The decompressed content, at offset rcPos.
+	rcPos int64
+	pos   int64
+}
+
+func (f *memZipFile) Stat() (fs.FileInfo, error) { return f.memNode, nil }
+
+func (f *memZipFile) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	if err := f.seekContent(); err != nil {
+		return 0, err
+	}
+	n, err := f.rc.Read(p)
+	f.pos += int64(n)
+	f.rcPos += int64(n)
+	return n, err
+}
+
+// seekContent moves the decompressed content to the current position,
+// reopening it when moving backwards.
+func (f *memZipFile) seekContent() error {
+	if f.rc == nil || f.pos < f.rcPos {
+		if f.rc != nil {
+			f.rc.Close()
+			f.rc = nil
+		}
+		rc, err := f.zf.Open()
+		if err != nil {
+			return err
+		}
+		f.rc, f.rcPos = rc, 0
+	}
+	n, err := io.CopyN(io.Discard, f.rc, f.pos-f.rcPos)
+	f.rcPos += n
+	return err
+}
+
+func (f *memZipFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *memZipFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: fs.ErrInvalid}
+	}
+	rc, err := f.zf.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	if _, err := io.CopyN(io.Discard, rc, off); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(rc, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memZipFile) Close() error {
+	if f.rc == nil {
+		return nil
+	}
+	err := f.rc.Close()
+	f.rc = nil
+	return err
+}
+
+type memDir struct {
+	*memNode
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.memNode, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *memDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	n := len(d.entries) - d.offset
+	if n == 0 && count > 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && n > count {
+		n = count
+	}
+	entries := d.entries[d.offset : d.offset+n]
+	d.offset += n
+	return entries, nil
+}
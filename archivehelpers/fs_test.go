@@ -0,0 +1,191 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestNewFS(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "a", "b"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "config.toml"), []byte("title = 'foo'"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a", "b", "c.txt"), []byte("hello"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a", "d.txt"), []byte("world"), 0o644), qt.IsNil)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+
+		fsys, err := NewFS(tp, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		c.Assert(err, qt.IsNil)
+		c.Assert(fstest.TestFS(fsys, "config.toml", "a/b/c.txt", "a/d.txt"), qt.IsNil)
+
+		b, err := fs.ReadFile(fsys, "a/b/c.txt")
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "hello")
+
+		entries, err := fs.ReadDir(fsys, "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(entries, qt.HasLen, 2)
+		c.Assert(entries[0].Name(), qt.Equals, "b")
+		c.Assert(entries[0].IsDir(), qt.IsTrue)
+
+		fi, err := fs.Stat(fsys, "a/d.txt")
+		c.Assert(err, qt.IsNil)
+		c.Assert(fi.Size(), qt.Equals, int64(5))
+
+		_, err = fsys.Open("nope.txt")
+		c.Assert(errors.Is(err, fs.ErrNotExist), qt.IsTrue)
+	}
+}
+
+func TestNewFSLinks(t *testing.T) {
+	c := qt.New(t)
+
+	r := newTestArchive(c, TypeTarGz,
+		testEntry{name: "./dir/file.txt", content: "hello"},
+		testEntry{name: "link.txt", typeflag: tar.TypeSymlink, linkname: "dir/file.txt"},
+		testEntry{name: "dirlink", typeflag: tar.TypeSymlink, linkname: "dir"},
+		testEntry{name: "hardlink.txt", typeflag: tar.TypeLink, linkname: "dir/file.txt"},
+		testEntry{name: "outside", typeflag: tar.TypeSymlink, linkname: "../foo"},
+	)
+	b, err := io.ReadAll(r)
+	c.Assert(err, qt.IsNil)
+
+	fsys, err := NewFS(TypeTarGz, bytes.NewReader(b), int64(len(b)))
+	c.Assert(err, qt.IsNil)
+
+	for _, name := range []string{"dir/file.txt", "link.txt", "dirlink/file.txt", "hardlink.txt"} {
+		b, err := fs.ReadFile(fsys, name)
+		c.Assert(err, qt.IsNil, qt.Commentf(name))
+		c.Assert(string(b), qt.Equals, "hello")
+	}
+
+	_, err = fs.ReadFile(fsys, "outside")
+	c.Assert(errors.Is(err, fs.ErrNotExist), qt.IsTrue)
+}
+
+func TestNewFSZipLinksAndLimits(t *testing.T) {
+	c := qt.New(t)
+
+	b, err := io.ReadAll(newTestArchive(c, TypeZip,
+		testEntry{name: "dir/file.txt", content: "hello"},
+		testEntry{name: "link.txt", typeflag: tar.TypeSymlink, linkname: "dir/file.txt"},
+		testEntry{name: "dirlink", typeflag: tar.TypeSymlink, linkname: "dir"},
+		testEntry{name: "outside", typeflag: tar.TypeSymlink, linkname: "../foo"},
+	))
+	c.Assert(err, qt.IsNil)
+
+	fsys, err := NewFS(TypeZip, bytes.NewReader(b), int64(len(b)))
+	c.Assert(err, qt.IsNil)
+	for _, name := range []string{"dir/file.txt", "link.txt", "dirlink/file.txt"} {
+		b, err := fs.ReadFile(fsys, name)
+		c.Assert(err, qt.IsNil, qt.Commentf(name))
+		c.Assert(string(b), qt.Equals, "hello")
+	}
+	_, err = fs.ReadFile(fsys, "outside")
+	c.Assert(errors.Is(err, fs.ErrNotExist), qt.IsTrue)
+
+	// A zip file claiming to be larger than the default MaxFileSize.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{Name: "big.txt", Method: zip.Store, CompressedSize64: 4, UncompressedSize64: 2 << 30})
+	c.Assert(err, qt.IsNil)
+	_, err = w.Write([]byte("abcd"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(zw.Close(), qt.IsNil)
+
+	_, err = NewFS(TypeZip, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	var limitErr *LimitError
+	c.Assert(errors.As(err, &limitErr), qt.IsTrue, qt.Commentf("%v", err))
+	c.Assert(limitErr.Limit, qt.Equals, "MaxFileSize")
+}
+
+func TestNewFSTarReadOnDemand(t *testing.T) {
+	c := qt.New(t)
+
+	content := strings.Repeat("a", 1<<20)
+	b, err := io.ReadAll(newTestArchive(c, TypeTar,
+		testEntry{name: "big.txt", content: content},
+		testEntry{name: "small.txt", content: "hello"},
+		testEntry{name: "hardlink.txt", typeflag: tar.TypeLink, linkname: "small.txt"},
+	))
+	c.Assert(err, qt.IsNil)
+
+	r := &countingReaderAt{r: bytes.NewReader(b)}
+	fsys, err := NewFS(TypeTar, r, int64(len(b)))
+	c.Assert(err, qt.IsNil)
+	c.Assert(r.n.Load() < 1<<16, qt.IsTrue, qt.Commentf("%d bytes read", r.n.Load()))
+
+	for name, want := range map[string]string{"big.txt": content, "small.txt": "hello", "hardlink.txt": "hello"} {
+		got, err := fs.ReadFile(fsys, name)
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(got) == want, qt.IsTrue, qt.Commentf(name))
+	}
+	c.Assert(fstest.TestFS(fsys, "big.txt", "small.txt", "hardlink.txt"), qt.IsNil)
+}
+
+func TestNewFSHTTP(t *testing.T) {
+	c := qt.New(t)
+
+	license := strings.Repeat("Permission is hereby granted, free of charge.\n", 100)
+	sourceDir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "LICENSE"), []byte(license), 0o644), qt.IsNil)
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+
+		fsys, err := NewFS(tp, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		c.Assert(err, qt.IsNil)
+		srv := httptest.NewServer(http.FileServer(http.FS(fsys)))
+
+		get := func(rangeHeader string) (*http.Response, string) {
+			req, err := http.NewRequest("GET", srv.URL+"/LICENSE", nil)
+			c.Assert(err, qt.IsNil)
+			if rangeHeader != "" {
+				req.Header.Set("Range", rangeHeader)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			c.Assert(err, qt.IsNil)
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			c.Assert(err, qt.IsNil)
+			return resp, string(b)
+		}
+
+		// The content type is sniffed from the content.
+		resp, body := get("")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusOK, qt.Commentf("%s: %s", tp, body))
+		c.Assert(resp.Header.Get("Content-Type"), qt.Equals, "text/plain; charset=utf-8")
+		c.Assert(body, qt.Equals, license)
+
+		resp, body = get("bytes=100-199")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusPartialContent, qt.Commentf("%s: %s", tp, body))
+		c.Assert(body, qt.Equals, license[100:200])
+
+		srv.Close()
+	}
+}
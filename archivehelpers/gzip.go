@@ -0,0 +1,238 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bep/helpers/parahelpers"
+)
+
+// GzipHeader holds the metadata stored in a gzip header.
+type GzipHeader struct {
+	// Name is the name of the compressed file.
+	Name string
+
+	// ModTime is the modification time of the compressed file.
+	ModTime time.Time
+
+	// Comment is a free form comment.
+	Comment string
+}
+
+// GzipOptions configures Gzip, GzipFile and GzipDirectory.
+type GzipOptions struct {
+	// Header is written to the gzip header.
+	// GzipFile uses the file's base name and modification time if Name and ModTime are not set.
+	// GzipDirectory always uses the name and modification time of each file.
+	Header GzipHeader
+
+	// CompressionLevel is the compression level to use, see compress/flate.
+	// The zero value means flate.BestCompression, use NoCompression to store the data uncompressed.
+	CompressionLevel int
+
+	// NoCompression stores the data without compression, overriding CompressionLevel.
+	NoCompression bool
+
+	// Workers is the number of files GzipDirectory compresses in parallel.
+	// The zero value means runtime.NumCPU.
+	Workers int
+}
+
+func (o GzipOptions) compressionLevel() int {
+	if o.NoCompression {
+		return flate.NoCompression
+	}
+	if o.CompressionLevel == 0 {
+		return flate.BestCompression
+	}
+	return o.CompressionLevel
+}
+
+// Gzip compresses src into dst as a single gzip member.
+func Gzip(dst io.Writer, src io.Reader, opts GzipOptions) error {
+	gw, err := gzip.NewWriterLevel(dst, opts.compressionLevel())
+	if err != nil {
+		return err
+	}
+	gw.Name = opts.Header.Name
+	gw.ModTime = opts.Header.ModTime
+	gw.Comment = opts.Header.Comment
+
+	if _, err := io.Copy(gw, src); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Gunzip decompresses src into dst.
+// Concatenated gzip members are decompressed as one stream.
+// The header returned is the one of the first member.
+func Gunzip(dst io.Writer, src io.Reader) (GzipHeader, error) {
+	gzr, err := gzip.NewReader(src)
+	if err != nil {
+		return GzipHeader{}, err
+	}
+	defer gzr.Close()
+
+	header := GzipHeader{Name: gzr.Name, ModTime: gzr.ModTime, Comment: gzr.Comment}
+	if _, err := io.Copy(dst, gzr); err != nil {
+		return header, err
+	}
+	return header, nil
+}
+
+// GzipFile compresses filename into filename + ".gz", replacing any existing file.
+// The modification time of the new file is set to that of filename.
+func GzipFile(filename string, opts GzipOptions) error {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if opts.Header.Name == "" && isLatin1(fi.Name()) {
+		// The gzip header can only hold Latin-1 strings.
+		opts.Header.Name = fi.Name()
+	}
+	if opts.Header.ModTime.IsZero() {
+		opts.Header.ModTime = fi.ModTime()
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return writeFileAtomic(filename+".gz", fi.Mode().Perm(), fi.ModTime(), func(w io.Writer) error {
+		return Gzip(w, f, opts)
+	})
+}
+
+// GunzipFile decompresses filename, which must have a ".gz" extension,
+// into the same path without the extension, replacing any existing file.
+// The name in the gzip header is never used.
+// The modification time of the new file is set to the one in the gzip header, if set.
+func GunzipFile(filename string) error {
+	target, found := strings.CutSuffix(filename, ".gz")
+	if !found || filepath.Base(filename) == ".gz" {
+		return fmt.Errorf("%q does not have a .gz extension", filename)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var header GzipHeader
+	if err := writeFileAtomic(target, fi.Mode().Perm(), time.Time{}, func(w io.Writer) error {
+		var err error
+		header, err = Gunzip(w, f)
+		return err
+	}); err != nil {
+		return err
+	}
+	if header.ModTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(target, header.ModTime, header.ModTime)
+}
+
+// GzipDirectory compresses all files below dir matching predicate into a .gz file next to it,
+// using GzipOptions.Workers goroutines.
+// The predicate receives the slash separated path relative to dir.
+// Files with a .gz extension are skipped.
+func GzipDirectory(ctx context.Context, dir string, predicate func(string) bool, opts GzipOptions) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	opts.Header.Name, opts.Header.ModTime = "", time.Time{}
+
+	runner, ctx := parahelpers.New(workers).Start(ctx)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(path, ".gz") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !predicate(filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		runner.Run(func() error {
+			return GzipFile(path, opts)
+		})
+		return nil
+	})
+
+	if werr := runner.Wait(); werr != nil {
+		return werr
+	}
+	return err
+}
+
+func isLatin1(s string) bool {
+	for _, r := range s {
+		if r > 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileAtomic writes filename using write via a temporary file in the same directory,
+// so filename is either left untouched or fully written.
+// If modTime is set, it is applied to the new file.
+func writeFileAtomic(filename string, perm fs.FileMode, modTime time.Time, write func(w io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(f.Name(), modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestGzip(t *testing.T) {
+	c := qt.New(t)
+
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	header := GzipHeader{Name: "sitemap.xml", ModTime: mtime, Comment: "hello"}
+
+	var buf bytes.Buffer
+	c.Assert(Gzip(&buf, strings.NewReader("first,"), GzipOptions{Header: header}), qt.IsNil)
+	// Concatenated members are read as one stream.
+	c.Assert(Gzip(&buf, strings.NewReader("second"), GzipOptions{}), qt.IsNil)
+
+	var out bytes.Buffer
+	got, err := Gunzip(&out, &buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out.String(), qt.Equals, "first,second")
+	c.Assert(got.Name, qt.Equals, header.Name)
+	c.Assert(got.Comment, qt.Equals, header.Comment)
+	c.Assert(got.ModTime.Equal(mtime), qt.IsTrue)
+
+	// Stored uncompressed, so the content is readable as is.
+	buf.Reset()
+	c.Assert(Gzip(&buf, strings.NewReader("stored"), GzipOptions{NoCompression: true}), qt.IsNil)
+	c.Assert(strings.Contains(buf.String(), "stored"), qt.IsTrue)
+
+	c.Assert(Gzip(&buf, strings.NewReader(""), GzipOptions{CompressionLevel: 42}), qt.IsNotNil)
+	_, err = Gunzip(&out, strings.NewReader("not gzip"))
+	c.Assert(err, qt.IsNotNil)
+}
+
+func TestGzipFile(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "style.css")
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.Assert(os.WriteFile(filename, []byte("body{}"), 0o644), qt.IsNil)
+	c.Assert(os.Chtimes(filename, mtime, mtime), qt.IsNil)
+
+	c.Assert(GzipFile(filename, GzipOptions{}), qt.IsNil)
+	fi, err := os.Stat(filename + ".gz")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), qt.IsTrue)
+
+	f, err := os.Open(filename + ".gz")
+	c.Assert(err, qt.IsNil)
+	var out bytes.Buffer
+	header, err := Gunzip(&out, f)
+	c.Assert(f.Close(), qt.IsNil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out.String(), qt.Equals, "body{}")
+	c.Assert(header.Name, qt.Equals, "style.css")
+	c.Assert(header.ModTime.Equal(mtime), qt.IsTrue)
+
+	c.Assert(os.Remove(filename), qt.IsNil)
+	c.Assert(GunzipFile(filename+".gz"), qt.IsNil)
+	b, err := os.ReadFile(filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "body{}")
+	fi, err = os.Stat(filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), qt.IsTrue)
+
+	c.Assert(GunzipFile(filename), qt.ErrorMatches, ".*does not have a .gz extension")
+
+	// Nothing is left behind on failure.
+	c.Assert(os.WriteFile(filepath.Join(dir, "bad.gz"), []byte("not gzip"), 0o644), qt.IsNil)
+	c.Assert(GunzipFile(filepath.Join(dir, "bad.gz")), qt.IsNotNil)
+	entries, err := os.ReadDir(dir)
+	c.Assert(err, qt.IsNil)
+	c.Assert(entries, qt.HasLen, 3)
+}
+
+func TestGzipDirectory(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.html", "b.css", "sub/c.html", "sub/d.png", "sub/e.html.gz"} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(name), 0o644), qt.IsNil)
+	}
+
+	predicate := func(name string) bool { return !strings.HasSuffix(name, ".png") }
+	c.Assert(GzipDirectory(context.Background(), dir, predicate, GzipOptions{Workers: 2}), qt.IsNil)
+
+	for _, name := range []string{"a.html", "b.css", "sub/c.html"} {
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)+".gz"))
+		c.Assert(err, qt.IsNil)
+		var out bytes.Buffer
+		header, err := Gunzip(&out, f)
+		c.Assert(f.Close(), qt.IsNil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(out.String(), qt.Equals, name)
+		c.Assert(header.Name, qt.Equals, filepath.Base(name))
+	}
+	for _, name := range []string{"sub/d.png.gz", "sub/e.html.gz.gz"} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		c.Assert(os.IsNotExist(err), qt.IsTrue)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(GzipDirectory(ctx, dir, predicate, GzipOptions{}), qt.ErrorIs, context.Canceled)
+}
@@ -0,0 +1,15 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+//go:build !unix
+
+package archivehelpers
+
+import "io/fs"
+
+type fileKey struct{}
+
+// hardLinkKey always reports false on this platform, hard links are archived as regular files.
+func hardLinkKey(info fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
@@ -0,0 +1,25 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+//go:build unix
+
+package archivehelpers
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkKey returns a key identifying the file behind info if it has more than one link.
+func hardLinkKey(info fs.FileInfo) (fileKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
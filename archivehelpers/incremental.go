@@ -0,0 +1,302 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// whiteoutPrefix marks a deleted file in an incremental archive,
+// e.g. "a/.wh.b.txt" means that "a/b.txt" was deleted, as in OCI image layers.
+const whiteoutPrefix = ".wh."
+
+// Snapshot records the state of the files in an archive.
+// It is used to create incremental archives with ArchiveDirectoryIncremental.
+type Snapshot struct {
+	// Files maps the slash separated path of each entry to its state.
+	Files map[string]SnapshotFile `json:"files"`
+}
+
+// SnapshotFile is the state of a file in a Snapshot.
+type SnapshotFile struct {
+	Size     int64       `json:"size"`
+	Mode     fs.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"modTime"`
+	Linkname string      `json:"linkname,omitempty"`
+}
+
+// changed reports whether f differs from prev.
+// Modification times are compared with second precision, as stored in archives,
+// which either truncate them (zip) or round them (tar) to whole seconds.
+func (f SnapshotFile) changed(prev SnapshotFile) bool {
+	d := f.ModTime.Sub(prev.ModTime)
+	return f.Size != prev.Size ||
+		f.Mode != prev.Mode ||
+		f.Linkname != prev.Linkname ||
+		d <= -time.Second || d >= time.Second
+}
+
+// NewSnapshot creates a Snapshot from the entries of an archive, see Archiver.Entries.
+// Use Apply to add the entries of any incremental archives on top.
+// Hard links get the state of the file they link to.
+// Note that archives created with ArchiveOptions.Reproducible do not record the real
+// modification times and permissions, so all files will be considered changed when such
+// a Snapshot is compared with the directory; use the Snapshot returned by
+// ArchiveDirectoryIncremental for these.
+func NewSnapshot(entries iter.Seq2[Entry, error]) (*Snapshot, error) {
+	s := &Snapshot{Files: make(map[string]SnapshotFile)}
+	if err := s.Apply(entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Apply updates s with the entries of an incremental archive,
+// removing the files deleted by whiteout entries.
+func (s *Snapshot) Apply(entries iter.Seq2[Entry, error]) error {
+	if s.Files == nil {
+		s.Files = make(map[string]SnapshotFile)
+	}
+	for e, err := range entries {
+		if err != nil {
+			return err
+		}
+		if name, ok := whiteoutTarget(e.Name); ok {
+			s.remove(name)
+			continue
+		}
+		f := SnapshotFile{Size: e.Size, Mode: e.Mode, ModTime: e.ModTime, Linkname: e.Linkname}
+		if e.Type == EntryTypeHardlink {
+			// On disk, this is a regular file like the one it links to.
+			target := s.Files[e.Linkname]
+			f.Size, f.Linkname = target.Size, ""
+		}
+		s.Files[e.Name] = f
+	}
+	return nil
+}
+
+// remove removes name and anything below it.
+func (s *Snapshot) remove(name string) {
+	for k := range s.Files {
+		if k == name || strings.HasPrefix(k, name+"/") {
+			delete(s.Files, k)
+		}
+	}
+}
+
+// ReadSnapshot reads a Snapshot written with Snapshot.Write.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]SnapshotFile)
+	}
+	return &s, nil
+}
+
+// Write writes s as JSON to w.
+func (s *Snapshot) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// whiteoutTarget returns the name of the file deleted by the given whiteout entry name.
+func whiteoutTarget(name string) (string, bool) {
+	dir, base := path.Split(name)
+	deleted, found := strings.CutPrefix(base, whiteoutPrefix)
+	if !found || deleted == "" {
+		return "", false
+	}
+	return dir + deleted, true
+}
+
+// whiteoutFile is an empty file marking a deletion in an incremental archive.
+type whiteoutFile struct {
+	name    string
+	modTime time.Time
+}
+
+func (f whiteoutFile) Open() (io.ReadCloser, error)       { return io.NopCloser(strings.NewReader("")), nil }
+func (f whiteoutFile) Readlink() (string, error)          { return "", fs.ErrInvalid }
+func (f whiteoutFile) Xattrs() (map[string]string, error) { return nil, nil }
+func (f whiteoutFile) Name() string                       { return f.name }
+func (f whiteoutFile) Size() int64                        { return 0 }
+func (f whiteoutFile) Mode() fs.FileMode                  { return 0o644 }
+func (f whiteoutFile) ModTime() time.Time                 { return f.modTime }
+func (f whiteoutFile) IsDir() bool                        { return false }
+func (f whiteoutFile) Sys() any                           { return nil }
+
+func (a *archivist) ArchiveDirectoryIncremental(ctx context.Context, directory string, predicate func(string) bool, prev *Snapshot, out io.WriteCloser) (next *Snapshot, err error) {
+	archive, err := a.newArchiveAdder(out)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	defer func() {
+		closeErr := archive.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			next = nil
+			if ctx.Err() != nil {
+				removeOutput(out)
+			}
+		}
+	}()
+
+	if prev == nil {
+		prev = &Snapshot{}
+	}
+	next = &Snapshot{Files: make(map[string]SnapshotFile)}
+
+	type change struct {
+		path       string
+		info       os.FileInfo
+		targetPath string
+	}
+	var changes []change
+
+	err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if info.IsDir() && (!a.opts.Archive.IncludeDirectories || path == directory) {
+			return nil
+		}
+
+		if !predicate(path) {
+			return nil
+		}
+
+		// ExtractChain would read these as deletions.
+		if strings.HasPrefix(info.Name(), whiteoutPrefix) {
+			return fmt.Errorf("%q: file names starting with %q are reserved for whiteouts in incremental archives", path, whiteoutPrefix)
+		}
+
+		f := SnapshotFile{Mode: info.Mode(), ModTime: info.ModTime()}
+		switch {
+		case info.Mode().IsRegular():
+			f.Size = info.Size()
+		case info.Mode()&fs.ModeSymlink != 0:
+			if f.Linkname, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		targetPath := strings.Trim(filepath.ToSlash(strings.TrimPrefix(path, directory)), "/")
+		next.Files[targetPath] = f
+		if p, found := prev.Files[targetPath]; !found || f.changed(p) {
+			changes = append(changes, change{path: path, info: info, targetPath: targetPath})
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	p := &archiveProgress{ctx: ctx, fn: a.opts.Archive.Progress}
+
+	// Deletions go first, so a file replaced by a directory with the same name
+	// is removed before the directory content is written.
+	// A directory replaced by a file or a symlink is removed as a whole.
+	var deleted []string
+	for name := range prev.Files {
+		if _, found := next.Files[name]; !found {
+			deleted = append(deleted, replacedDir(name, next))
+		}
+	}
+	slices.Sort(deleted)
+	deleted = slices.Compact(deleted)
+	now := time.Now()
+	var removedDir string
+	for _, name := range deleted {
+		if removedDir != "" && strings.HasPrefix(name, removedDir+"/") {
+			// Removed with its parent directory.
+			continue
+		}
+		removedDir = name
+		dir, base := path.Split(name)
+		whiteout := whiteoutFile{name: whiteoutPrefix + base, modTime: now}
+		if err = p.add(archive, whiteout, whiteout, dir+whiteout.name); err != nil {
+			return
+		}
+	}
+
+	for _, c := range changes {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = p.add(archive, osFile(c.path), c.info, c.targetPath); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// replacedDir returns the parent directory of the deleted file name that is no longer a directory in next,
+// or name itself if there is none.
+func replacedDir(name string, next *Snapshot) string {
+	for i, r := range name {
+		if r != '/' {
+			continue
+		}
+		if f, found := next.Files[name[:i]]; found && !f.Mode.IsDir() {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+func (a *archivist) ExtractChain(ctx context.Context, targetDir string, archives ...io.ReadCloser) error {
+	for _, in := range archives {
+		defer in.Close()
+	}
+	if a.opts.Extract.Signature != nil && len(archives) > 1 {
+		return errors.New("a detached signature can not be used with more than one archive")
+	}
+
+	readers := make([]io.Reader, len(archives))
+	for i, in := range archives {
+		r, cleanup, err := a.verify(in, a.opts.Extract.Signature)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		readers[i] = r
+	}
+	return a.extractArchives(ctx, targetDir, true, readers...)
+}
+
+// whiteout removes name, relative to the target directory, and anything below it.
+func (x *extraction) whiteout(name string) error {
+	target, err := x.resolve(name)
+	if err != nil {
+		return err
+	}
+	if target == x.targetDir {
+		return &UnsafePathError{Name: name}
+	}
+	return os.RemoveAll(target)
+}
@@ -0,0 +1,234 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestArchiveDirectoryIncremental(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	all := func(string) bool { return true }
+	writeFile := func(dir, name, content string) {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o644), qt.IsNil)
+	}
+	entryNames := func(a Archiver, archive []byte) []string {
+		var names []string
+		for e, err := range a.Entries(io.NopCloser(bytes.NewReader(archive))) {
+			c.Assert(err, qt.IsNil)
+			names = append(names, e.Name)
+		}
+		return names
+	}
+
+	for _, tp := range []Type{TypeTarGz, TypeTar, TypeZip} {
+		sourceDir := t.TempDir()
+		writeFile(sourceDir, "a.txt", "a")
+		writeFile(sourceDir, "sub/b.txt", "b")
+		writeFile(sourceDir, "sub/c.txt", "c")
+
+		a, err := New(tp)
+		c.Assert(err, qt.IsNil)
+
+		var base bytes.Buffer
+		snap1, err := a.ArchiveDirectoryIncremental(ctx, sourceDir, all, nil, nopWriteCloser{&base})
+		c.Assert(err, qt.IsNil)
+		c.Assert(entryNames(a, base.Bytes()), qt.DeepEquals, []string{"a.txt", "sub/b.txt", "sub/c.txt"})
+
+		// Save and load the snapshot.
+		var snapBuf bytes.Buffer
+		c.Assert(snap1.Write(&snapBuf), qt.IsNil)
+		loaded, err := ReadSnapshot(&snapBuf)
+		c.Assert(err, qt.IsNil)
+		c.Assert(slices.Sorted(maps.Keys(loaded.Files)), qt.DeepEquals, []string{"a.txt", "sub/b.txt", "sub/c.txt"})
+
+		writeFile(sourceDir, "a.txt", "a changed")
+		writeFile(sourceDir, "d.txt", "d")
+		c.Assert(os.RemoveAll(filepath.Join(sourceDir, "sub")), qt.IsNil)
+
+		var inc bytes.Buffer
+		snap2, err := a.ArchiveDirectoryIncremental(ctx, sourceDir, all, loaded, nopWriteCloser{&inc})
+		c.Assert(err, qt.IsNil)
+		c.Assert(entryNames(a, inc.Bytes()), qt.DeepEquals, []string{"sub/.wh.b.txt", "sub/.wh.c.txt", "a.txt", "d.txt"})
+
+		// A snapshot built from the archive chain has the same files.
+		fromArchives, err := NewSnapshot(a.Entries(io.NopCloser(bytes.NewReader(base.Bytes()))))
+		c.Assert(err, qt.IsNil)
+		c.Assert(fromArchives.Apply(a.Entries(io.NopCloser(bytes.NewReader(inc.Bytes())))), qt.IsNil)
+		c.Assert(slices.Sorted(maps.Keys(fromArchives.Files)), qt.DeepEquals, slices.Sorted(maps.Keys(snap2.Files)))
+
+		// Nothing changed.
+		var empty bytes.Buffer
+		_, err = a.ArchiveDirectoryIncremental(ctx, sourceDir, all, snap2, nopWriteCloser{&empty})
+		c.Assert(err, qt.IsNil)
+		c.Assert(entryNames(a, empty.Bytes()), qt.HasLen, 0)
+
+		targetDir := t.TempDir()
+		c.Assert(a.ExtractChain(ctx, targetDir,
+			io.NopCloser(bytes.NewReader(base.Bytes())),
+			io.NopCloser(bytes.NewReader(inc.Bytes())),
+			io.NopCloser(bytes.NewReader(empty.Bytes())),
+		), qt.IsNil)
+		for name, content := range map[string]string{"a.txt": "a changed", "d.txt": "d"} {
+			b, err := os.ReadFile(filepath.Join(targetDir, name))
+			c.Assert(err, qt.IsNil)
+			c.Assert(string(b), qt.Equals, content)
+		}
+		entries, err := os.ReadDir(filepath.Join(targetDir, "sub"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(entries, qt.HasLen, 0)
+	}
+}
+
+func TestExtractChainWhiteouts(t *testing.T) {
+	c := qt.New(t)
+
+	a, err := New(TypeTarGz)
+	c.Assert(err, qt.IsNil)
+
+	targetDir := t.TempDir()
+	c.Assert(a.ExtractChain(context.Background(), targetDir,
+		newTestArchive(c, TypeTarGz,
+			testEntry{name: "dir/a.txt", content: "a"},
+			testEntry{name: "dir/b.txt", content: "b"},
+			testEntry{name: "c.txt", content: "c"},
+		),
+		newTestArchive(c, TypeTarGz,
+			testEntry{name: ".wh.dir"},
+			testEntry{name: "dir/b.txt", content: "b2"},
+		),
+	), qt.IsNil)
+
+	_, err = os.Stat(filepath.Join(targetDir, "dir", "a.txt"))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+	b, err := os.ReadFile(filepath.Join(targetDir, "dir", "b.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "b2")
+
+	// Whiteouts can not remove anything outside the target directory.
+	err = a.ExtractChain(context.Background(), targetDir, newTestArchive(c, TypeTarGz, testEntry{name: "../.wh.c.txt"}))
+	c.Assert(errors.Is(err, ErrUnsafePath), qt.IsTrue)
+	err = a.ExtractChain(context.Background(), targetDir, newTestArchive(c, TypeTarGz, testEntry{name: "dir/../.wh.."}))
+	c.Assert(errors.Is(err, ErrUnsafePath), qt.IsTrue)
+	_, err = os.Stat(filepath.Join(targetDir, "c.txt"))
+	c.Assert(err, qt.IsNil)
+
+	// Plain Extract writes whiteouts as regular files.
+	c.Assert(a.Extract(newTestArchive(c, TypeTarGz, testEntry{name: ".wh.c.txt"}), targetDir), qt.IsNil)
+	_, err = os.Stat(filepath.Join(targetDir, "c.txt"))
+	c.Assert(err, qt.IsNil)
+	_, err = os.Stat(filepath.Join(targetDir, ".wh.c.txt"))
+	c.Assert(err, qt.IsNil)
+}
+
+func TestArchiveDirectoryIncrementalDirReplacedByFile(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	all := func(string) bool { return true }
+
+	sourceDir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(sourceDir, "a", "sub"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a", "x.txt"), []byte("x"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a", "sub", "y.txt"), []byte("y"), 0o644), qt.IsNil)
+
+	a, err := New(TypeTarGz)
+	c.Assert(err, qt.IsNil)
+
+	var base bytes.Buffer
+	snap, err := a.ArchiveDirectoryIncremental(ctx, sourceDir, all, nil, nopWriteCloser{&base})
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(os.RemoveAll(filepath.Join(sourceDir, "a")), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a"), []byte("now a file"), 0o644), qt.IsNil)
+
+	var inc bytes.Buffer
+	_, err = a.ArchiveDirectoryIncremental(ctx, sourceDir, all, snap, nopWriteCloser{&inc})
+	c.Assert(err, qt.IsNil)
+
+	var names []string
+	for e, err := range a.Entries(io.NopCloser(bytes.NewReader(inc.Bytes()))) {
+		c.Assert(err, qt.IsNil)
+		names = append(names, e.Name)
+	}
+	c.Assert(names, qt.DeepEquals, []string{".wh.a", "a"})
+
+	targetDir := t.TempDir()
+	c.Assert(a.ExtractChain(ctx, targetDir,
+		io.NopCloser(bytes.NewReader(base.Bytes())),
+		io.NopCloser(bytes.NewReader(inc.Bytes())),
+	), qt.IsNil)
+	b, err := os.ReadFile(filepath.Join(targetDir, "a"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "now a file")
+}
+
+func TestNewSnapshotHardlinks(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	all := func(string) bool { return true }
+
+	sourceDir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("hello"), 0o644), qt.IsNil)
+	c.Assert(os.Link(filepath.Join(sourceDir, "a.txt"), filepath.Join(sourceDir, "b.txt")), qt.IsNil)
+
+	a, err := New(TypeTarGz)
+	c.Assert(err, qt.IsNil)
+
+	var base bytes.Buffer
+	c.Assert(a.ArchiveDirectory(sourceDir, all, nopWriteCloser{&base}), qt.IsNil)
+
+	snap, err := NewSnapshot(a.Entries(io.NopCloser(bytes.NewReader(base.Bytes()))))
+	c.Assert(err, qt.IsNil)
+	c.Assert(snap.Files["b.txt"].Size, qt.Equals, int64(5))
+	c.Assert(snap.Files["b.txt"].Linkname, qt.Equals, "")
+
+	// Nothing changed.
+	var inc bytes.Buffer
+	_, err = a.ArchiveDirectoryIncremental(ctx, sourceDir, all, snap, nopWriteCloser{&inc})
+	c.Assert(err, qt.IsNil)
+	for e, err := range a.Entries(io.NopCloser(bytes.NewReader(inc.Bytes()))) {
+		c.Assert(err, qt.IsNil)
+		c.Fatalf("unexpected entry %q", e.Name)
+	}
+}
+
+func TestArchiveDirectoryIncrementalReservedNames(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	all := func(string) bool { return true }
+
+	sourceDir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "config"), []byte("config"), 0o644), qt.IsNil)
+
+	a, err := New(TypeTarGz)
+	c.Assert(err, qt.IsNil)
+
+	var base bytes.Buffer
+	snap, err := a.ArchiveDirectoryIncremental(ctx, sourceDir, all, nil, nopWriteCloser{&base})
+	c.Assert(err, qt.IsNil)
+
+	// A user file that would be read as a deletion of config.
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, ".wh.config"), []byte("user data"), 0o644), qt.IsNil)
+
+	var inc bytes.Buffer
+	_, err = a.ArchiveDirectoryIncremental(ctx, sourceDir, all, snap, nopWriteCloser{&inc})
+	c.Assert(err, qt.ErrorMatches, `.*file names starting with ".wh." are reserved for whiteouts.*`)
+}
@@ -0,0 +1,173 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// ErrLimitExceeded is returned (wrapped in a LimitError) when Extract hits one of the configured Limits.
+var ErrLimitExceeded = errors.New("archive limit exceeded")
+
+// Limits protects Extract against decompression bombs.
+// A zero value means that the default is used, a negative value disables the limit.
+type Limits struct {
+	// MaxTotalSize is the maximum number of uncompressed bytes to extract.
+	// Default is 4 GiB.
+	MaxTotalSize int64
+
+	// MaxFileSize is the maximum uncompressed size of a single file.
+	// Default is 1 GiB.
+	MaxFileSize int64
+
+	// MaxEntries is the maximum number of entries in the archive.
+	// Default is 100 000.
+	MaxEntries int
+
+	// MaxCompressionRatio is the maximum ratio between the number of uncompressed bytes extracted
+	// and the number of compressed bytes read.
+	// This is first checked when more than 1 MiB has been extracted.
+	// Default is 200.
+	MaxCompressionRatio int
+}
+
+const (
+	defaultMaxTotalSize        = 4 << 30
+	defaultMaxFileSize         = 1 << 30
+	defaultMaxEntries          = 100000
+	defaultMaxCompressionRatio = 200
+
+	// Small archives may have a high compression ratio, e.g. a file with a single repeated byte.
+	compressionRatioThreshold = 1 << 20
+)
+
+func (l Limits) init() Limits {
+	if l.MaxTotalSize == 0 {
+		l.MaxTotalSize = defaultMaxTotalSize
+	}
+	if l.MaxFileSize == 0 {
+		l.MaxFileSize = defaultMaxFileSize
+	}
+	if l.MaxEntries == 0 {
+		l.MaxEntries = defaultMaxEntries
+	}
+	if l.MaxCompressionRatio == 0 {
+		l.MaxCompressionRatio = defaultMaxCompressionRatio
+	}
+	return l
+}
+
+// LimitError is returned when Extract hits one of the configured Limits.
+type LimitError struct {
+	// Name is the name of the archive entry being extracted when the limit was hit.
+	Name string
+
+	// Limit is the name of the limit, e.g. "MaxFileSize".
+	Limit string
+
+	// Value is the configured value of the limit.
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s (%d) exceeded in %q", ErrLimitExceeded, e.Limit, e.Value, e.Name)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// checkEntry checks the limits before extracting an entry with the given declared size.
+func (x *extraction) checkEntry(name string, size int64) error {
+	l := x.opts.Limits
+	if l.MaxEntries > 0 && x.entries > l.MaxEntries {
+		return &LimitError{Name: name, Limit: "MaxEntries", Value: int64(l.MaxEntries)}
+	}
+	if l.MaxFileSize > 0 && size > l.MaxFileSize {
+		return &LimitError{Name: name, Limit: "MaxFileSize", Value: l.MaxFileSize}
+	}
+	if l.MaxTotalSize > 0 && x.totalSize+size > l.MaxTotalSize {
+		return &LimitError{Name: name, Limit: "MaxTotalSize", Value: l.MaxTotalSize}
+	}
+	return nil
+}
+
+// entrySize returns the size of the entry in header to check against the limits,
+// including the link target of symlinks, which zip archives store as the entry content.
+func entrySize(header *tar.Header) int64 {
+	if header.Typeflag == tar.TypeSymlink {
+		return max(header.Size, int64(len(header.Linkname)))
+	}
+	return header.Size
+}
+
+// checkSize checks the limits after fileSize bytes of the entry with the given name have been extracted.
+func (x *extraction) checkSize(name string, fileSize int64) error {
+	l := x.opts.Limits
+	if l.MaxFileSize > 0 && fileSize > l.MaxFileSize {
+		return &LimitError{Name: name, Limit: "MaxFileSize", Value: l.MaxFileSize}
+	}
+	if l.MaxTotalSize > 0 && x.totalSize > l.MaxTotalSize {
+		return &LimitError{Name: name, Limit: "MaxTotalSize", Value: l.MaxTotalSize}
+	}
+	if l.MaxCompressionRatio > 0 && x.totalSize > compressionRatioThreshold {
+		if bytesRead := x.r.BytesRead(); bytesRead > 0 && x.totalSize/bytesRead > int64(l.MaxCompressionRatio) {
+			return &LimitError{Name: name, Limit: "MaxCompressionRatio", Value: int64(l.MaxCompressionRatio)}
+		}
+	}
+	return nil
+}
+
+// limitedReader enforces the limits while reading the content of a single entry.
+// It also stops on cancellation and reports progress.
+type limitedReader struct {
+	x    *extraction
+	name string
+	r    io.Reader
+	n    int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if err := r.x.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	r.x.totalSize += int64(n)
+	if limitErr := r.x.checkSize(r.name, r.n); limitErr != nil {
+		return n, limitErr
+	}
+	if n > 0 {
+		r.x.progress(r.name)
+	}
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// countingReaderAt counts the bytes read from r.
+// It is safe for concurrent use, as the files of a zip archive may be read concurrently.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n atomic.Int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	r.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestExtractLimits(t *testing.T) {
+	c := qt.New(t)
+
+	bomb := testEntry{name: "zeros.txt", content: strings.Repeat("0", 10<<20)}
+	small := testEntry{name: "small.txt", content: "hello"}
+
+	for _, tp := range []Type{TypeTarGz, TypeZip} {
+		for _, test := range []struct {
+			name    string
+			limits  Limits
+			entries []testEntry
+			limit   string
+		}{
+			{"default ratio", Limits{}, []testEntry{bomb}, "MaxCompressionRatio"},
+			{"ratio disabled", Limits{MaxCompressionRatio: -1}, []testEntry{bomb}, ""},
+			{"file size", Limits{MaxFileSize: 3}, []testEntry{small}, "MaxFileSize"},
+			{"total size", Limits{MaxTotalSize: 8}, []testEntry{small, small}, "MaxTotalSize"},
+			{"entries", Limits{MaxEntries: 1}, []testEntry{small, small}, "MaxEntries"},
+			{"within limits", Limits{MaxEntries: 2, MaxTotalSize: 10}, []testEntry{small, small}, ""},
+		} {
+			c.Run(tp.String()+"/"+test.name, func(c *qt.C) {
+				a, err := NewWithOptions(tp, Options{Extract: ExtractOptions{Limits: test.limits}})
+				c.Assert(err, qt.IsNil)
+				err = a.Extract(newTestArchive(c, tp, test.entries...), c.TempDir())
+				if test.limit == "" {
+					c.Assert(err, qt.IsNil)
+					return
+				}
+				c.Assert(errors.Is(err, ErrLimitExceeded), qt.IsTrue, qt.Commentf("%v", err))
+				var limitErr *LimitError
+				c.Assert(errors.As(err, &limitErr), qt.IsTrue)
+				c.Assert(limitErr.Limit, qt.Equals, test.limit)
+			})
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestExtractPreserveMetadata(t *testing.T) {
+	c := qt.New(t)
+
+	mtime := time.Date(2019, 5, 6, 7, 8, 10, 0, time.UTC)
+	atime := time.Date(2019, 5, 7, 7, 8, 10, 0, time.UTC)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, h := range []*tar.Header{
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o750, ModTime: mtime, Uid: 1234, Gid: 5678},
+		{Name: "dir/file.txt", Typeflag: tar.TypeReg, Mode: 0o666, ModTime: mtime, AccessTime: atime, Size: 5, Uid: 1234, Gid: 5678, Format: tar.FormatPAX},
+		{Name: "dir/exec.sh", Typeflag: tar.TypeReg, Mode: 0o777, ModTime: mtime, Size: 5, Uid: 1234, Gid: 5678},
+	} {
+		c.Assert(tw.WriteHeader(h), qt.IsNil)
+		if h.Size > 0 {
+			_, err := tw.Write([]byte("hello"))
+			c.Assert(err, qt.IsNil)
+		}
+	}
+	c.Assert(tw.Close(), qt.IsNil)
+	c.Assert(gw.Close(), qt.IsNil)
+	archive := buf.Bytes()
+
+	a, err := NewWithOptions(TypeTarGz, Options{Extract: ExtractOptions{
+		PreserveTimes:       true,
+		PreservePermissions: true,
+		Umask:               0o002,
+		PreserveOwner:       true,
+	}})
+	c.Assert(err, qt.IsNil)
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(bytes.NewReader(archive)), targetDir), qt.IsNil)
+
+	for _, test := range []struct {
+		name  string
+		perm  os.FileMode
+		atime time.Time
+	}{
+		{"dir", 0o750, mtime},
+		{"dir/file.txt", 0o664, atime},
+		{"dir/exec.sh", 0o775, mtime},
+	} {
+		filename := filepath.Join(targetDir, filepath.FromSlash(test.name))
+		fi, err := os.Stat(filename)
+		c.Assert(err, qt.IsNil)
+		c.Assert(fi.Mode().Perm(), qt.Equals, test.perm, qt.Commentf(test.name))
+		c.Assert(fi.ModTime().Equal(mtime), qt.IsTrue, qt.Commentf("%s: %s", test.name, fi.ModTime()))
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			c.Assert(time.Unix(st.Atim.Unix()).Equal(test.atime), qt.IsTrue, qt.Commentf(test.name))
+			if os.Geteuid() == 0 {
+				c.Assert(st.Uid, qt.Equals, uint32(1234))
+				c.Assert(st.Gid, qt.Equals, uint32(5678))
+			}
+		}
+	}
+
+	// Without the options, the times are not restored.
+	a, err = New(TypeTarGz)
+	c.Assert(err, qt.IsNil)
+	targetDir = t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(bytes.NewReader(archive)), targetDir), qt.IsNil)
+	fi, err := os.Stat(filepath.Join(targetDir, "dir", "file.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), qt.IsFalse)
+}
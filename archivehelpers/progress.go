@@ -0,0 +1,94 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Progress describes the progress of an archive or extract operation.
+type Progress struct {
+	// Name is the name of the current entry.
+	Name string
+
+	// BytesDone is the number of uncompressed bytes processed so far.
+	BytesDone int64
+
+	// EntriesDone is the number of entries completed so far.
+	EntriesDone int
+}
+
+func (x *extraction) progress(name string) {
+	if x.opts.Progress == nil {
+		return
+	}
+	x.opts.Progress(Progress{Name: name, BytesDone: x.totalSize, EntriesDone: x.entriesDone})
+}
+
+// archiveProgress tracks cancellation and progress when archiving.
+type archiveProgress struct {
+	ctx context.Context
+	fn  func(Progress)
+
+	bytesDone   int64
+	entriesDone int
+}
+
+func (p *archiveProgress) add(archive archiveAdder, src fileSource, info fs.FileInfo, targetPath string) error {
+	if err := archive.Add(progressSource{fileSource: src, p: p, name: targetPath}, info, targetPath); err != nil {
+		return err
+	}
+	p.entriesDone++
+	p.report(targetPath)
+	return nil
+}
+
+func (p *archiveProgress) report(name string) {
+	if p.fn == nil {
+		return
+	}
+	p.fn(Progress{Name: name, BytesDone: p.bytesDone, EntriesDone: p.entriesDone})
+}
+
+// progressSource is a fileSource that stops on cancellation and reports progress while being read.
+type progressSource struct {
+	fileSource
+	p    *archiveProgress
+	name string
+}
+
+func (s progressSource) Open() (io.ReadCloser, error) {
+	rc, err := s.fileSource.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &progressReader{ReadCloser: rc, s: s}, nil
+}
+
+type progressReader struct {
+	io.ReadCloser
+	s progressSource
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	if err := r.s.p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.ReadCloser.Read(b)
+	if n > 0 {
+		r.s.p.bytesDone += int64(n)
+		r.s.p.report(r.s.name)
+	}
+	return n, err
+}
+
+// removeOutput removes out if it is a file on disk.
+func removeOutput(out io.WriteCloser) {
+	if f, ok := out.(*os.File); ok {
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestProgress(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		c.Assert(os.WriteFile(filepath.Join(sourceDir, name), []byte(strings.Repeat("x", 100)), 0o644), qt.IsNil)
+	}
+
+	for _, tp := range []Type{TypeTarGz, TypeZip} {
+		var archiveProgress, extractProgress []Progress
+		a, err := NewWithOptions(tp, Options{
+			Archive: ArchiveOptions{Progress: func(p Progress) { archiveProgress = append(archiveProgress, p) }},
+			Extract: ExtractOptions{Progress: func(p Progress) { extractProgress = append(extractProgress, p) }},
+		})
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectoryContext(context.Background(), sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		c.Assert(a.ExtractContext(context.Background(), io.NopCloser(&buf), t.TempDir()), qt.IsNil)
+
+		for _, progress := range [][]Progress{archiveProgress, extractProgress} {
+			c.Assert(len(progress) >= 3, qt.IsTrue)
+			last := progress[len(progress)-1]
+			c.Assert(last, qt.DeepEquals, Progress{Name: "c.txt", BytesDone: 300, EntriesDone: 3})
+		}
+	}
+}
+
+func TestExtractContextCancel(t *testing.T) {
+	c := qt.New(t)
+
+	for _, tp := range []Type{TypeTarGz, TypeZip} {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		a, err := NewWithOptions(tp, Options{
+			Extract: ExtractOptions{Progress: func(p Progress) {
+				if p.EntriesDone == 2 {
+					cancel()
+				}
+			}},
+		})
+		c.Assert(err, qt.IsNil)
+
+		targetDir := t.TempDir()
+		c.Assert(os.WriteFile(filepath.Join(targetDir, "existing.txt"), []byte("existing"), 0o644), qt.IsNil)
+
+		err = a.ExtractContext(ctx, newTestArchive(c, tp,
+			testEntry{name: "a/a.txt", content: "a"},
+			testEntry{name: "b/b.txt", content: "b"},
+			testEntry{name: "c/c.txt", content: "c"},
+		), targetDir)
+		c.Assert(errors.Is(err, context.Canceled), qt.IsTrue)
+
+		// Only the file that was there before should be left.
+		entries, err := os.ReadDir(targetDir)
+		c.Assert(err, qt.IsNil)
+		c.Assert(entries, qt.HasLen, 1)
+		c.Assert(entries[0].Name(), qt.Equals, "existing.txt")
+	}
+}
+
+func TestArchiveDirectoryContextCancel(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		c.Assert(os.WriteFile(filepath.Join(sourceDir, name), []byte("x"), 0o644), qt.IsNil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, err := NewWithOptions(TypeTarGz, Options{
+		Archive: ArchiveOptions{Progress: func(p Progress) {
+			if p.EntriesDone == 1 {
+				cancel()
+			}
+		}},
+	})
+	c.Assert(err, qt.IsNil)
+
+	archiveFilename := filepath.Join(t.TempDir(), "archive.tar.gz")
+	f, err := os.Create(archiveFilename)
+	c.Assert(err, qt.IsNil)
+
+	err = a.ArchiveDirectoryContext(ctx, sourceDir, func(string) bool { return true }, f)
+	c.Assert(errors.Is(err, context.Canceled), qt.IsTrue)
+	_, err = os.Stat(archiveFilename)
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+}
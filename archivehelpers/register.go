@@ -0,0 +1,224 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Format describes an archive format to register with RegisterType.
+// By default, registered formats are tar archives compressed with the given codec, e.g. tar.zst.
+// Other formats set NewReader and NewWriter to read and write the entries themselves.
+// Either way, they get the same entry handling, security checks and options as the built-in formats.
+type Format struct {
+	// Name is the name of the format, e.g. "tar.zst".
+	// This is what Type.String returns and what ParseType accepts.
+	Name string
+
+	// Extensions are the filename extensions, including the leading dot, e.g. ".tar.zst" and ".tzst".
+	// These are used by TypeFromFilename.
+	Extensions []string
+
+	// Magic holds the bytes that identify the format, used by DetectType.
+	Magic [][]byte
+
+	// MagicOffset is the offset of Magic in the archive, e.g. 257 for tar.
+	MagicOffset int
+
+	// NewCompressor creates the compressing writer used when archiving.
+	// level is ArchiveOptions.CompressionLevel, zero means the codec's default.
+	// If nil while NewDecompressor is set, archiving is not supported.
+	NewCompressor func(w io.Writer, level int) (io.WriteCloser, error)
+
+	// NewDecompressor creates the decompressing reader used when extracting.
+	// If nil, the archive is read uncompressed.
+	NewDecompressor func(r io.Reader) (io.ReadCloser, error)
+
+	// NewWriter creates the writer for the entries of the archive, on top of NewCompressor if set.
+	// level is ArchiveOptions.CompressionLevel.
+	// If nil, tar is used, unless NewReader is set, in which case archiving is not supported.
+	NewWriter func(w io.Writer, level int) (FormatWriter, error)
+
+	// NewReader creates the reader for the entries of the archive, on top of NewDecompressor if set.
+	// If nil, tar is used.
+	// At least one of NewDecompressor and NewReader must be set.
+	NewReader func(r io.Reader) (FormatReader, error)
+}
+
+// FormatWriter writes the entries of an archive in a registered Format.
+// It has the same semantics as *tar.Writer, which implements it.
+type FormatWriter interface {
+	// WriteHeader writes header and prepares to accept the file's content,
+	// which is written with Write for regular files.
+	WriteHeader(header *tar.Header) error
+
+	io.Writer
+
+	// Close finishes the archive, but does not close the underlying writer.
+	Close() error
+}
+
+// FormatReader reads the entries of an archive in a registered Format.
+// It has the same semantics as *tar.Reader, which implements it.
+type FormatReader interface {
+	// Next advances to the next entry, returning io.EOF at the end of the archive.
+	// The Typeflag of the header decides how the entry is extracted.
+	Next() (*tar.Header, error)
+
+	// Read reads from the content of the current entry.
+	io.Reader
+}
+
+// typeInfo describes an archive Type.
+type typeInfo struct {
+	typ         Type
+	name        string
+	extensions  []string
+	magic       [][]byte
+	newArchiver func() archiver
+
+	// magicOffset is the offset of magic in the archive, e.g. 257 for tar.
+	magicOffset int
+
+	// Set for the formats using compress/flate, used to validate the compression level.
+	flate bool
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = []*typeInfo{
+		{
+			typ:        TypeTarGz,
+			name:       "tar.gz",
+			extensions: []string{".tar.gz", ".tgz"},
+			magic:      [][]byte{{0x1f, 0x8b}},
+			newArchiver: func() archiver {
+				return &tarExtractor{newCompressor: newGzipCompressor, newDecompressor: newGzipDecompressor}
+			},
+			flate: true,
+		},
+		{
+			typ:        TypeZip,
+			name:       "zip",
+			extensions: []string{".zip"},
+			magic:      [][]byte{[]byte("PK\x03\x04"), []byte("PK\x05\x06")},
+			newArchiver: func() archiver {
+				return &zipExtractor{}
+			},
+			flate: true,
+		},
+		{
+			typ:         TypeTar,
+			name:        "tar",
+			extensions:  []string{".tar"},
+			magic:       [][]byte{[]byte("ustar")},
+			magicOffset: 257,
+			newArchiver: func() archiver {
+				return &tarExtractor{newCompressor: newNopCompressor, newDecompressor: newNopDecompressor}
+			},
+		},
+		{
+			typ:        TypeTarBz2,
+			name:       "tar.bz2",
+			extensions: []string{".tar.bz2", ".tbz2"},
+			magic:      [][]byte{[]byte("BZh")},
+			newArchiver: func() archiver {
+				return &tarExtractor{newDecompressor: newBzip2Decompressor}
+			},
+		},
+	}
+)
+
+// RegisterType registers a new archive format and returns its Type.
+// The Type can then be used with New, and is picked up by
+// Type.String, ParseType, TypeFromFilename and DetectType.
+func RegisterType(f Format) (Type, error) {
+	if f.Name == "" {
+		return TypeUnknown, errors.New("format name must be set")
+	}
+	if f.NewDecompressor == nil && f.NewReader == nil {
+		return TypeUnknown, fmt.Errorf("format %q: NewDecompressor or NewReader must be set", f.Name)
+	}
+	if f.NewWriter != nil && f.NewReader == nil {
+		return TypeUnknown, fmt.Errorf("format %q: NewWriter requires NewReader", f.Name)
+	}
+	for _, magic := range f.Magic {
+		if f.MagicOffset < 0 || f.MagicOffset+len(magic) > sniffLen {
+			return TypeUnknown, fmt.Errorf("format %q: magic must be within the first %d bytes", f.Name, sniffLen)
+		}
+	}
+
+	ti := &typeInfo{
+		name:        strings.ToLower(f.Name),
+		magic:       f.Magic,
+		magicOffset: f.MagicOffset,
+	}
+	for _, ext := range f.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			return TypeUnknown, fmt.Errorf("format %q: extension %q must start with a dot", f.Name, ext)
+		}
+		ti.extensions = append(ti.extensions, strings.ToLower(ext))
+	}
+
+	e := tarExtractor{newCompressor: newNopCompressor, newDecompressor: newNopDecompressor}
+	if f.NewDecompressor != nil {
+		e.newDecompressor = f.NewDecompressor
+		e.newCompressor = nil
+		if f.NewCompressor != nil {
+			e.newCompressor = func(w io.Writer, opts ArchiveOptions) (io.WriteCloser, error) {
+				return f.NewCompressor(w, opts.CompressionLevel)
+			}
+		}
+	}
+	if f.NewReader != nil {
+		e.newReader = f.NewReader
+		if f.NewWriter != nil {
+			e.newWriter = func(w io.Writer, opts ArchiveOptions) (FormatWriter, error) {
+				return f.NewWriter(w, opts.CompressionLevel)
+			}
+		} else {
+			e.newCompressor = nil
+		}
+	}
+	ti.newArchiver = func() archiver {
+		e := e
+		return &e
+	}
+
+	typesMu.Lock()
+	defer typesMu.Unlock()
+
+	for _, existing := range types {
+		if existing.name == ti.name {
+			return TypeUnknown, fmt.Errorf("format %q is already registered", f.Name)
+		}
+		ti.typ = max(ti.typ, existing.typ)
+	}
+	ti.typ++
+	types = append(types, ti)
+
+	return ti.typ, nil
+}
+
+func lookupType(typ Type) *typeInfo {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	for _, ti := range types {
+		if ti.typ == typ {
+			return ti
+		}
+	}
+	return nil
+}
+
+func typeInfos() []*typeInfo {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	return types[:len(types):len(types)]
+}
@@ -0,0 +1,231 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// A test codec that just prefixes the tar stream with a magic string.
+var testMagic = []byte("TST1")
+
+type testCompressor struct {
+	io.Writer
+}
+
+func (testCompressor) Close() error { return nil }
+
+// Registered once so the tests can be run with -count > 1.
+var registerTestTypes = sync.OnceValues(func() ([]Type, error) {
+	typ, err := RegisterType(Format{
+		Name:       "tar.test",
+		Extensions: []string{".tar.test", ".ttest"},
+		Magic:      [][]byte{testMagic},
+		NewCompressor: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if _, err := w.Write(testMagic); err != nil {
+				return nil, err
+			}
+			return testCompressor{w}, nil
+		},
+		NewDecompressor: func(r io.Reader) (io.ReadCloser, error) {
+			magic := make([]byte, len(testMagic))
+			if _, err := io.ReadFull(r, magic); err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(magic, testMagic) {
+				return nil, errors.New("invalid magic")
+			}
+			return io.NopCloser(r), nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract only.
+	typ2, err := RegisterType(Format{
+		Name:            "tar.extractonly",
+		NewDecompressor: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(r), nil },
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Not tar based, with the magic after a version number.
+	typ3, err := RegisterType(Format{
+		Name:        "gobarchive",
+		Extensions:  []string{".gobarchive"},
+		Magic:       [][]byte{gobArchiveMagic},
+		MagicOffset: 4,
+		NewWriter: func(w io.Writer, level int) (FormatWriter, error) {
+			if _, err := w.Write(append([]byte("v001"), gobArchiveMagic...)); err != nil {
+				return nil, err
+			}
+			return &gobArchiveWriter{enc: gob.NewEncoder(w)}, nil
+		},
+		NewReader: func(r io.Reader) (FormatReader, error) {
+			head := make([]byte, 4+len(gobArchiveMagic))
+			if _, err := io.ReadFull(r, head); err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(head[4:], gobArchiveMagic) {
+				return nil, errors.New("invalid magic")
+			}
+			return &gobArchiveReader{dec: gob.NewDecoder(r)}, nil
+		},
+	})
+
+	return []Type{typ, typ2, typ3}, err
+})
+
+var gobArchiveMagic = []byte("GOBARCHIVE")
+
+type gobArchiveEntry struct {
+	Header  *tar.Header
+	Content []byte
+}
+
+// gobArchiveWriter writes each entry as a gob encoded gobArchiveEntry.
+type gobArchiveWriter struct {
+	enc     *gob.Encoder
+	current *gobArchiveEntry
+}
+
+func (w *gobArchiveWriter) flush() error {
+	if w.current == nil {
+		return nil
+	}
+	err := w.enc.Encode(w.current)
+	w.current = nil
+	return err
+}
+
+func (w *gobArchiveWriter) WriteHeader(header *tar.Header) error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.current = &gobArchiveEntry{Header: header}
+	return nil
+}
+
+func (w *gobArchiveWriter) Write(p []byte) (int, error) {
+	w.current.Content = append(w.current.Content, p...)
+	return len(p), nil
+}
+
+func (w *gobArchiveWriter) Close() error {
+	return w.flush()
+}
+
+type gobArchiveReader struct {
+	dec     *gob.Decoder
+	content *bytes.Reader
+}
+
+func (r *gobArchiveReader) Next() (*tar.Header, error) {
+	var e gobArchiveEntry
+	if err := r.dec.Decode(&e); err != nil {
+		return nil, err
+	}
+	r.content = bytes.NewReader(e.Content)
+	return e.Header, nil
+}
+
+func (r *gobArchiveReader) Read(p []byte) (int, error) {
+	return r.content.Read(p)
+}
+
+func TestRegisterType(t *testing.T) {
+	c := qt.New(t)
+
+	types, err := registerTestTypes()
+	c.Assert(err, qt.IsNil)
+	typ, typ2, typ3 := types[0], types[1], types[2]
+	c.Assert(typ > TypeZip, qt.IsTrue)
+	c.Assert(typ2, qt.Equals, typ+1)
+
+	c.Assert(typ.String(), qt.Equals, "tar.test")
+	parsed, err := ParseType("ttest")
+	c.Assert(err, qt.IsNil)
+	c.Assert(parsed, qt.Equals, typ)
+	c.Assert(TypeFromFilename("foo.TAR.TEST"), qt.Equals, typ)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("hello"), 0o644), qt.IsNil)
+
+	a, err := New(typ)
+	c.Assert(err, qt.IsNil)
+	var buf bytes.Buffer
+	c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+
+	detected, r, err := DetectType(&buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(detected, qt.Equals, typ)
+
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(r), targetDir), qt.IsNil)
+	b, err := os.ReadFile(filepath.Join(targetDir, "a.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "hello")
+
+	_, err = RegisterType(Format{Name: "TAR.TEST", NewDecompressor: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(r), nil }})
+	c.Assert(err, qt.ErrorMatches, `format "TAR.TEST" is already registered`)
+	_, err = RegisterType(Format{Name: "tar.nodecompressor"})
+	c.Assert(err, qt.IsNotNil)
+	_, err = RegisterType(Format{Name: "tar.badext", Extensions: []string{"tbe"}, NewDecompressor: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(r), nil }})
+	c.Assert(err, qt.IsNotNil)
+
+	_, err = RegisterType(Format{Name: "badmagic", Magic: [][]byte{testMagic}, MagicOffset: sniffLen, NewDecompressor: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(r), nil }})
+	c.Assert(err, qt.ErrorMatches, `format "badmagic": magic must be within the first 512 bytes`)
+
+	a, err = New(typ2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{io.Discard}), qt.ErrorMatches, "archiving is not supported.*")
+
+	// A format that is not tar based.
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "b.txt"), []byte("world"), 0o644), qt.IsNil)
+	a, err = New(typ3)
+	c.Assert(err, qt.IsNil)
+	buf.Reset()
+	c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+
+	detected, r, err = DetectType(bytes.NewReader(buf.Bytes()))
+	c.Assert(err, qt.IsNil)
+	c.Assert(detected, qt.Equals, typ3)
+
+	targetDir = t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(r), targetDir), qt.IsNil)
+	b, err = os.ReadFile(filepath.Join(targetDir, "b.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "world")
+
+	var names []string
+	for e, err := range a.Entries(io.NopCloser(bytes.NewReader(buf.Bytes()))) {
+		c.Assert(err, qt.IsNil)
+		names = append(names, e.Name)
+	}
+	c.Assert(names, qt.DeepEquals, []string{"a.txt", "b.txt"})
+
+	// The same security checks apply.
+	var evil bytes.Buffer
+	w, err := a.(*archivist).archiver.NewArchiveAdder(nopWriteCloser{&evil}, ArchiveOptions{})
+	c.Assert(err, qt.IsNil)
+	info, err := os.Lstat(filepath.Join(sourceDir, "a.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(w.Add(osFile(filepath.Join(sourceDir, "a.txt")), info, "../evil.txt"), qt.IsNil)
+	c.Assert(w.Close(), qt.IsNil)
+	var unsafeErr *UnsafePathError
+	c.Assert(errors.As(a.Extract(io.NopCloser(&evil), t.TempDir()), &unsafeErr), qt.IsTrue)
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultModTime is the modification time used in Reproducible mode if no other time is set.
+var defaultModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (o ArchiveOptions) init() ArchiveOptions {
+	if !o.Reproducible || !o.ModTime.IsZero() {
+		return o
+	}
+	if s := os.Getenv("SOURCE_DATE_EPOCH"); s != "" {
+		if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+			o.ModTime = time.Unix(secs, 0)
+		}
+	}
+	return o
+}
+
+func (o ArchiveOptions) modTime(t time.Time) time.Time {
+	if o.ModTime.IsZero() {
+		return defaultModTime
+	}
+	if t.After(o.ModTime) {
+		t = o.ModTime
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (o ArchiveOptions) perm(mode fs.FileMode) fs.FileMode {
+	if mode.Type() == fs.ModeSymlink {
+		return 0o777
+	}
+	if mode.IsDir() || mode&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
+
+func (o ArchiveOptions) normalizeTarHeader(h *tar.Header) {
+	if !o.Reproducible {
+		return
+	}
+	h.ModTime = o.modTime(h.ModTime)
+	h.AccessTime = time.Time{}
+	h.ChangeTime = time.Time{}
+	h.Uid, h.Gid = 0, 0
+	h.Uname, h.Gname = "", ""
+	h.Mode = int64(o.perm(h.FileInfo().Mode()))
+}
+
+func (o ArchiveOptions) normalizeZipHeader(h *zip.FileHeader) {
+	if !o.Reproducible {
+		return
+	}
+	mode := h.Mode()
+	h.Modified = o.modTime(h.Modified)
+	h.SetMode(mode.Type() | o.perm(mode))
+}
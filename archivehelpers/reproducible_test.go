@@ -0,0 +1,89 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestArchiveReproducible(t *testing.T) {
+	c := qt.New(t)
+
+	createTree := func(mtime time.Time, perm os.FileMode) string {
+		dir := t.TempDir()
+		c.Assert(os.MkdirAll(filepath.Join(dir, "b"), 0o755), qt.IsNil)
+		for _, name := range []string{"a.txt", "b/c.txt", "b/d.txt"} {
+			filename := filepath.Join(dir, filepath.FromSlash(name))
+			c.Assert(os.WriteFile(filename, []byte(name), perm), qt.IsNil)
+			c.Assert(os.Chmod(filename, perm), qt.IsNil)
+			c.Assert(os.Chtimes(filename, mtime, mtime), qt.IsNil)
+		}
+		return dir
+	}
+
+	dir1 := createTree(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), 0o600)
+	dir2 := createTree(time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC), 0o664)
+
+	archive := func(tp Type, opts ArchiveOptions, dir string) []byte {
+		a, err := NewWithOptions(tp, Options{Archive: opts})
+		c.Assert(err, qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(dir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		return buf.Bytes()
+	}
+
+	for _, tp := range []Type{TypeTarGz, TypeZip} {
+		opts := ArchiveOptions{Reproducible: true}
+		c.Assert(archive(tp, opts, dir1), qt.DeepEquals, archive(tp, opts, dir2), qt.Commentf("%s", tp))
+		c.Assert(archive(tp, ArchiveOptions{}, dir1), qt.Not(qt.DeepEquals), archive(tp, ArchiveOptions{}, dir2))
+	}
+
+	readHeaders := func(b []byte) []*tar.Header {
+		gzr, err := gzip.NewReader(bytes.NewReader(b))
+		c.Assert(err, qt.IsNil)
+		c.Assert(gzr.Header.ModTime.IsZero(), qt.IsTrue)
+		tr := tar.NewReader(gzr)
+		var headers []*tar.Header
+		for {
+			h, err := tr.Next()
+			if err != nil {
+				break
+			}
+			headers = append(headers, h)
+		}
+		return headers
+	}
+
+	headers := readHeaders(archive(TypeTarGz, ArchiveOptions{Reproducible: true}, dir1))
+	c.Assert(headers, qt.HasLen, 3)
+	for _, h := range headers {
+		c.Assert(h.ModTime.Equal(defaultModTime), qt.IsTrue)
+		c.Assert(h.Mode, qt.Equals, int64(0o644))
+		c.Assert(h.Uid, qt.Equals, 0)
+		c.Assert(h.Uname, qt.Equals, "")
+	}
+
+	// Clamp to SOURCE_DATE_EPOCH.
+	epoch := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	t.Setenv("SOURCE_DATE_EPOCH", "1640995200")
+	for _, dir := range []string{dir1, dir2} {
+		for _, h := range readHeaders(archive(TypeTarGz, ArchiveOptions{Reproducible: true}, dir)) {
+			fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(h.Name)))
+			c.Assert(err, qt.IsNil)
+			expect := fi.ModTime()
+			if expect.After(epoch) {
+				expect = epoch
+			}
+			c.Assert(h.ModTime.Equal(expect), qt.IsTrue, qt.Commentf("%s: %s", h.Name, h.ModTime))
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when an archive signature is missing
+// or does not verify against any of the given public keys.
+var ErrInvalidSignature = errors.New("invalid archive signature")
+
+// SignArchive returns a detached ed25519 signature of the SHA-256 digest of the archive in r.
+func SignArchive(r io.Reader, key ed25519.PrivateKey) ([]byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 private key size")
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(key, h.Sum(nil)), nil
+}
+
+// VerifyArchive verifies a detached signature created with SignArchive
+// of the archive in r against keys.
+// It returns an error wrapping ErrInvalidSignature if none of the keys verify the signature.
+func VerifyArchive(r io.Reader, signature []byte, keys ...ed25519.PublicKey) error {
+	if err := validatePublicKeys(keys); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	return verifyDigest(h.Sum(nil), signature, keys)
+}
+
+func validatePublicKeys(keys []ed25519.PublicKey) error {
+	if len(keys) == 0 {
+		return errors.New("no public keys given")
+	}
+	for _, key := range keys {
+		if len(key) != ed25519.PublicKeySize {
+			return errors.New("invalid ed25519 public key size")
+		}
+	}
+	return nil
+}
+
+func verifyDigest(digest, signature []byte, keys []ed25519.PublicKey) error {
+	for _, key := range keys {
+		if ed25519.Verify(key, digest, signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Embedded signatures are stored in a fixed size trailer at the end of the archive,
+// covering the SHA-256 digest of all bytes before it:
+// an empty gzip member with the signature in its extra field for tar.gz,
+// and the archive comment for zip.
+const (
+	gzipSignatureID    = "ed"
+	zipSignaturePrefix = "ed25519:"
+)
+
+// supportsEmbeddedSignature reports whether typ supports ArchiveOptions.SigningKey.
+func supportsEmbeddedSignature(typ Type) bool {
+	return typ == TypeTarGz || typ == TypeZip
+}
+
+// signatureTrailer returns the trailer holding sig for archives of type typ.
+func signatureTrailer(typ Type, sig []byte) []byte {
+	if typ == TypeZip {
+		return []byte(zipSignaturePrefix + hex.EncodeToString(sig))
+	}
+
+	extra := make([]byte, 4, 4+len(sig))
+	copy(extra, gzipSignatureID)
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(sig)))
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Extra = append(extra, sig...)
+	gw.Close()
+	return buf.Bytes()
+}
+
+// zipSignaturePlaceholder is the archive comment written by zip archives
+// to be signed, replaced with the signature when done.
+func zipSignaturePlaceholder() string {
+	return string(signatureTrailer(TypeZip, make([]byte, ed25519.SignatureSize)))
+}
+
+// embeddedSignature returns the signature embedded in the archive in ra
+// and the size of the signed content before it.
+func embeddedSignature(typ Type, ra io.ReaderAt, size int64) ([]byte, int64, error) {
+	errMissing := fmt.Errorf("%w: no embedded signature found", ErrInvalidSignature)
+
+	n := int64(len(signatureTrailer(typ, make([]byte, ed25519.SignatureSize))))
+	if size < n {
+		return nil, 0, errMissing
+	}
+	trailer := make([]byte, n)
+	if _, err := ra.ReadAt(trailer, size-n); err != nil {
+		return nil, 0, err
+	}
+
+	var sig []byte
+	if typ == TypeZip {
+		s, found := strings.CutPrefix(string(trailer), zipSignaturePrefix)
+		if !found {
+			return nil, 0, errMissing
+		}
+		sig, _ = hex.DecodeString(s)
+	} else {
+		gzr, err := gzip.NewReader(bytes.NewReader(trailer))
+		if err != nil {
+			return nil, 0, errMissing
+		}
+		if len(gzr.Extra) > 4 && string(gzr.Extra[:2]) == gzipSignatureID {
+			sig = gzr.Extra[4:]
+		}
+	}
+
+	// The trailer must be exactly what would have been written for sig.
+	if len(sig) != ed25519.SignatureSize || !bytes.Equal(trailer, signatureTrailer(typ, sig)) {
+		return nil, 0, errMissing
+	}
+
+	return sig, size - n, nil
+}
+
+// signingWriter hashes everything written through it except for the last hold bytes,
+// which are held back and replaced with the signature trailer on Close.
+type signingWriter struct {
+	out  io.WriteCloser
+	typ  Type
+	key  ed25519.PrivateKey
+	h    hash.Hash
+	hold int
+	buf  []byte
+}
+
+func newSigningWriter(out io.WriteCloser, typ Type, key ed25519.PrivateKey) *signingWriter {
+	w := &signingWriter{out: out, typ: typ, key: key, h: sha256.New()}
+	if typ == TypeZip {
+		w.hold = len(zipSignaturePlaceholder())
+	}
+	return w
+}
+
+func (w *signingWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if n := len(w.buf) - w.hold; n > 0 {
+		if _, err := w.out.Write(w.buf[:n]); err != nil {
+			return 0, err
+		}
+		w.h.Write(w.buf[:n])
+		w.buf = append(w.buf[:0], w.buf[n:]...)
+	}
+	return len(p), nil
+}
+
+func (w *signingWriter) Close() error {
+	if w.typ == TypeZip && string(w.buf) != zipSignaturePlaceholder() {
+		w.out.Close()
+		return errors.New("archive does not end with the signature placeholder")
+	}
+	sig := ed25519.Sign(w.key, w.h.Sum(nil))
+	if _, err := w.out.Write(signatureTrailer(w.typ, sig)); err != nil {
+		w.out.Close()
+		return err
+	}
+	return w.out.Close()
+}
+
+// newArchiveAdder creates the archiveAdder to write to out,
+// signing the archive if ArchiveOptions.SigningKey is set.
+func (a *archivist) newArchiveAdder(out io.WriteCloser) (archiveAdder, error) {
+	if a.opts.Archive.SigningKey != nil {
+		out = newSigningWriter(out, a.typ, a.opts.Archive.SigningKey)
+	}
+	return a.archiver.NewArchiveAdder(out, a.opts.Archive)
+}
+
+// verify verifies the signature of the archive in in if ExtractOptions.PublicKeys is set,
+// using signature if set, else the embedded signature.
+// Nothing is extracted before this is done, so the archive is spooled to a private temporary file,
+// even if it is a regular file, so the bytes extracted are the bytes verified.
+// It returns a reader for the verified archive and a func to call when done with it.
+func (a *archivist) verify(in io.Reader, signature []byte) (io.Reader, func(), error) {
+	keys := a.opts.Extract.PublicKeys
+	if len(keys) == 0 {
+		return in, func() {}, nil
+	}
+
+	ra, size, cleanup, err := spoolToTempFile(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signedSize := size
+	if signature == nil {
+		signature, signedSize, err = embeddedSignature(a.typ, ra, size)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(ra, 0, signedSize)); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := verifyDigest(h.Sum(nil), signature, keys); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return io.NewSectionReader(ra, 0, size), cleanup, nil
+}
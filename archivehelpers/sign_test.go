@@ -0,0 +1,131 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSignEmbedded(t *testing.T) {
+	c := qt.New(t)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	c.Assert(err, qt.IsNil)
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	c.Assert(err, qt.IsNil)
+
+	sourceDir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("hello"), 0o644), qt.IsNil)
+
+	for _, tp := range []Type{TypeTarGz, TypeZip} {
+		a, err := NewWithOptions(tp, Options{Archive: ArchiveOptions{SigningKey: priv}})
+		c.Assert(err, qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&buf}), qt.IsNil)
+		signed := buf.Bytes()
+
+		extract := func(archive []byte, keys ...ed25519.PublicKey) (string, error) {
+			a, err := NewWithOptions(tp, Options{Extract: ExtractOptions{PublicKeys: keys}})
+			c.Assert(err, qt.IsNil)
+			targetDir := filepath.Join(t.TempDir(), "target")
+			return targetDir, a.Extract(io.NopCloser(bytes.NewReader(archive)), targetDir)
+		}
+
+		targetDir, err := extract(signed, otherPub, pub)
+		c.Assert(err, qt.IsNil, qt.Commentf("%s", tp))
+		b, err := os.ReadFile(filepath.Join(targetDir, "a.txt"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "hello")
+
+		// Signed archives can still be extracted without verification.
+		a, err = New(tp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(a.Extract(io.NopCloser(bytes.NewReader(signed)), t.TempDir()), qt.IsNil)
+
+		var unsigned bytes.Buffer
+		c.Assert(a.ArchiveDirectory(sourceDir, func(string) bool { return true }, nopWriteCloser{&unsigned}), qt.IsNil)
+
+		tampered := bytes.Clone(signed)
+		tampered[len(tampered)/3] ^= 0xff
+
+		for _, test := range []struct {
+			archive []byte
+			key     ed25519.PublicKey
+		}{
+			{signed, otherPub},
+			{unsigned.Bytes(), pub},
+			{tampered, pub},
+		} {
+			targetDir, err := extract(test.archive, test.key)
+			c.Assert(errors.Is(err, ErrInvalidSignature), qt.IsTrue, qt.Commentf("%s: %v", tp, err))
+			// Nothing is written.
+			_, err = os.Stat(targetDir)
+			c.Assert(os.IsNotExist(err), qt.IsTrue)
+		}
+	}
+
+	_, err = NewWithOptions(TypeTar, Options{Archive: ArchiveOptions{SigningKey: priv}})
+	c.Assert(err, qt.ErrorMatches, "signing is not supported for type tar")
+	_, err = NewWithOptions(TypeTarGz, Options{Extract: ExtractOptions{PublicKeys: []ed25519.PublicKey{pub[:3]}}})
+	c.Assert(err, qt.ErrorMatches, "invalid ed25519 public key size")
+}
+
+func TestSignDetached(t *testing.T) {
+	c := qt.New(t)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	c.Assert(err, qt.IsNil)
+
+	archive, err := io.ReadAll(newTestArchive(c, TypeTar, testEntry{name: "a.txt", content: "hello"}))
+	c.Assert(err, qt.IsNil)
+	sig, err := SignArchive(bytes.NewReader(archive), priv)
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(VerifyArchive(bytes.NewReader(archive), sig, pub), qt.IsNil)
+	c.Assert(VerifyArchive(bytes.NewReader(archive[1:]), sig, pub), qt.ErrorIs, ErrInvalidSignature)
+
+	a, err := NewWithOptions(TypeTar, Options{Extract: ExtractOptions{PublicKeys: []ed25519.PublicKey{pub}, Signature: sig}})
+	c.Assert(err, qt.IsNil)
+
+	filename := filepath.Join(t.TempDir(), "archive.tar")
+	c.Assert(os.WriteFile(filename, archive, 0o644), qt.IsNil)
+	f, err := os.Open(filename)
+	c.Assert(err, qt.IsNil)
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(f, targetDir), qt.IsNil)
+	b, err := os.ReadFile(filepath.Join(targetDir, "a.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "hello")
+
+	// What is extracted is what was verified, even if the file changes in between.
+	f, err = os.Open(filename)
+	c.Assert(err, qt.IsNil)
+	defer f.Close()
+	r, cleanup, err := a.(*archivist).verify(f, sig)
+	c.Assert(err, qt.IsNil)
+	defer cleanup()
+	evil, err := io.ReadAll(newTestArchive(c, TypeTar, testEntry{name: "a.txt", content: "evil!"}))
+	c.Assert(err, qt.IsNil)
+	c.Assert(os.WriteFile(filename, evil, 0o644), qt.IsNil)
+	verified, err := io.ReadAll(r)
+	c.Assert(err, qt.IsNil)
+	c.Assert(verified, qt.DeepEquals, archive)
+
+	// A signature without keys to verify it against.
+	_, err = NewWithOptions(TypeTar, Options{Extract: ExtractOptions{Signature: sig}})
+	c.Assert(err, qt.ErrorMatches, "a signature can not be verified without PublicKeys")
+
+	other := newTestArchive(c, TypeTar, testEntry{name: "b.txt", content: "evil"})
+	c.Assert(a.Extract(other, targetDir), qt.ErrorIs, ErrInvalidSignature)
+	_, err = os.Stat(filepath.Join(targetDir, "b.txt"))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+}
@@ -0,0 +1,37 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"slices"
+	"strings"
+)
+
+// paxXattr prefixes the PAX records holding extended attributes,
+// as used by GNU tar, bsdtar and archive/tar.
+const paxXattr = "SCHILY.xattr."
+
+// defaultXattrNamespaces are the extended attribute namespaces restored by default,
+// see ExtractOptions.XattrNamespaces.
+var defaultXattrNamespaces = []string{"user"}
+
+// xattrsFromPAX returns the extended attributes in the given namespaces stored in the given PAX records.
+func xattrsFromPAX(records map[string]string, namespaces []string) map[string]string {
+	var xattrs map[string]string
+	for k, v := range records {
+		name, found := strings.CutPrefix(k, paxXattr)
+		if !found || name == "" {
+			continue
+		}
+		namespace, _, _ := strings.Cut(name, ".")
+		if !slices.Contains(namespaces, namespace) {
+			continue
+		}
+		if xattrs == nil {
+			xattrs = make(map[string]string)
+		}
+		xattrs[name] = v
+	}
+	return xattrs
+}
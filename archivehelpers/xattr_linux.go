@@ -0,0 +1,92 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package archivehelpers
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// readXattrs returns the extended attributes of filename.
+// File systems without extended attribute support have none.
+func readXattrs(filename string) (map[string]string, error) {
+	names, err := xattrCall(func(dest []byte) (int, error) {
+		return syscall.Listxattr(filename, dest)
+	})
+	if err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, &os.PathError{Op: "listxattr", Path: filename, Err: err}
+	}
+
+	var xattrs map[string]string
+	for name := range strings.SplitSeq(strings.TrimSuffix(string(names), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		value, err := xattrCall(func(dest []byte) (int, error) {
+			return syscall.Getxattr(filename, name, dest)
+		})
+		if err != nil {
+			if errors.Is(err, syscall.ENODATA) {
+				// Removed in the meantime.
+				continue
+			}
+			return nil, &os.PathError{Op: "getxattr", Path: filename, Err: err}
+		}
+		if xattrs == nil {
+			xattrs = make(map[string]string)
+		}
+		xattrs[name] = string(value)
+	}
+	return xattrs, nil
+}
+
+// writeXattrs sets the extended attributes in xattrs on filename, in name order.
+// Attributes not supported by the file system are skipped.
+func writeXattrs(filename string, xattrs map[string]string) error {
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := syscall.Setxattr(filename, name, []byte(xattrs[name]), 0); err != nil {
+			if errors.Is(err, syscall.ENOTSUP) {
+				continue
+			}
+			return &os.PathError{Op: "setxattr", Path: filename, Err: err}
+		}
+	}
+	return nil
+}
+
+// xattrCall calls fn with a buffer large enough for the result,
+// retrying if the value grows between the calls.
+func xattrCall(fn func(dest []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := fn(nil)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := fn(buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
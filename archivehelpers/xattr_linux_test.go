@@ -0,0 +1,103 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestXattrs(t *testing.T) {
+	c := qt.New(t)
+
+	sourceDir := t.TempDir()
+	filename := filepath.Join(sourceDir, "a.txt")
+	c.Assert(os.WriteFile(filename, []byte("a"), 0o644), qt.IsNil)
+	if err := syscall.Setxattr(filename, "user.test", []byte("hello"), 0); err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			c.Skip("extended attributes not supported")
+		}
+		c.Fatal(err)
+	}
+	c.Assert(syscall.Setxattr(sourceDir, "user.dir", []byte("d"), 0), qt.IsNil)
+
+	a, err := NewWithOptions(TypeTarGz, Options{
+		Archive: ArchiveOptions{Xattrs: true, IncludeDirectories: true},
+		Extract: ExtractOptions{RestoreXattrs: true},
+	})
+	c.Assert(err, qt.IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(a.ArchiveFiles([]File{{Source: sourceDir, Path: "dir"}, {Source: filename, Path: "dir/a.txt"}}, nopWriteCloser{&buf}), qt.IsNil)
+
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(bytes.NewReader(buf.Bytes())), targetDir), qt.IsNil)
+
+	xattrs, err := readXattrs(filepath.Join(targetDir, "dir", "a.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(xattrs["user.test"], qt.Equals, "hello")
+	xattrs, err = readXattrs(filepath.Join(targetDir, "dir"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(xattrs["user.dir"], qt.Equals, "d")
+
+	// Not restored unless asked for.
+	a, err = New(TypeTarGz)
+	c.Assert(err, qt.IsNil)
+	targetDir = t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(bytes.NewReader(buf.Bytes())), targetDir), qt.IsNil)
+	xattrs, err = readXattrs(filepath.Join(targetDir, "dir", "a.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(xattrs["user.test"], qt.Equals, "")
+}
+
+func TestXattrNamespaces(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	c.Assert(tw.WriteHeader(&tar.Header{
+		Name:     "a.txt",
+		Typeflag: tar.TypeReg,
+		Mode:     0o644,
+		PAXRecords: map[string]string{
+			paxXattr + "user.test":     "hello",
+			paxXattr + "trusted.test":  "trusted",
+			paxXattr + "security.test": "security",
+		},
+	}), qt.IsNil)
+	c.Assert(tw.Close(), qt.IsNil)
+
+	extract := func(namespaces []string) (map[string]string, error) {
+		a, err := NewWithOptions(TypeTar, Options{Extract: ExtractOptions{RestoreXattrs: true, XattrNamespaces: namespaces}})
+		c.Assert(err, qt.IsNil)
+		targetDir := t.TempDir()
+		if err := a.Extract(io.NopCloser(bytes.NewReader(buf.Bytes())), targetDir); err != nil {
+			return nil, err
+		}
+		return readXattrs(filepath.Join(targetDir, "a.txt"))
+	}
+
+	xattrs, err := extract(nil)
+	if errors.Is(err, syscall.ENOTSUP) || (err == nil && xattrs["user.test"] == "") {
+		c.Skip("extended attributes not supported")
+	}
+	c.Assert(err, qt.IsNil)
+	// Only the user namespace by default.
+	c.Assert(xattrs, qt.DeepEquals, map[string]string{"user.test": "hello"})
+
+	if os.Geteuid() == 0 {
+		xattrs, err = extract([]string{"user", "trusted"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(xattrs["trusted.test"], qt.Equals, "trusted")
+		c.Assert(xattrs["security.test"], qt.Equals, "")
+	}
+}
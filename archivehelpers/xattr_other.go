@@ -0,0 +1,18 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package archivehelpers
+
+// readXattrs returns the extended attributes of filename,
+// which is only supported on Linux.
+func readXattrs(filename string) (map[string]string, error) {
+	return nil, nil
+}
+
+// writeXattrs sets the extended attributes in xattrs on filename,
+// which is only supported on Linux.
+func writeXattrs(filename string, xattrs map[string]string) error {
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+type zipArchiver struct {
+	out  io.WriteCloser
+	zw   *zip.Writer
+	opts ArchiveOptions
+}
+
+func (a *zipArchiver) Add(src fileSource, info fs.FileInfo, targetPath string) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = targetPath
+	header.Method = zip.Deflate
+	if info.IsDir() {
+		header.Name += "/"
+		header.Method = zip.Store
+	}
+	a.opts.normalizeZipHeader(header)
+
+	if a.opts.Checksums && info.Mode().IsRegular() {
+		sum, err := fileChecksum(src)
+		if err != nil {
+			return err
+		}
+		header.Comment = zipCommentSHA256 + sum
+	}
+
+	w, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+
+	// Symlinks are stored with the link target as content, as done by Info-ZIP.
+	if info.Mode()&fs.ModeSymlink != 0 {
+		link, err := src.Readlink()
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, link)
+		return err
+	}
+
+	f, err := src.Open()
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *zipArchiver) Close() error {
+	if err := a.zw.Close(); err != nil {
+		return err
+	}
+
+	return a.out.Close()
+}
+
+type zipExtractor struct{}
+
+func (e *zipExtractor) NewArchiveAdder(out io.WriteCloser, opts ArchiveOptions) (archiveAdder, error) {
+	zw := zip.NewWriter(out)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, opts.compressionLevel())
+	})
+	if opts.SigningKey != nil {
+		// Replaced with the signature, see signingWriter.
+		if err := zw.SetComment(zipSignaturePlaceholder()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &zipArchiver{
+		out:  out,
+		zw:   zw,
+		opts: opts,
+	}, nil
+}
+
+func (e *zipExtractor) NewEntryReader(in io.Reader) (entryReader, error) {
+	ra, size, cleanup, err := toReaderAt(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count the bytes actually read, the sizes in the central directory can not be trusted.
+	cr := &countingReaderAt{r: ra}
+	zr, err := zip.NewReader(cr, size)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	cr.n.Store(0)
+
+	return &zipEntryReader{files: zr.File, cr: cr, cleanup: cleanup}, nil
+}
+
+type zipEntryReader struct {
+	files       []*zip.File
+	currentFile *zip.File
+	current     io.ReadCloser
+	cr          *countingReaderAt
+	cleanup     func()
+}
+
+func (r *zipEntryReader) BytesRead() int64 {
+	return r.cr.n.Load()
+}
+
+func (r *zipEntryReader) Next() (*tar.Header, io.Reader, error) {
+	if err := r.closeCurrent(); err != nil {
+		return nil, nil, err
+	}
+	if len(r.files) == 0 {
+		return nil, nil, io.EOF
+	}
+	zf := r.files[0]
+	r.files = r.files[1:]
+	r.currentFile = zf
+
+	header, err := tar.FileInfoHeader(zf.FileInfo(), "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", zf.Name, err)
+	}
+	header.Name = zf.Name
+	if sum := checksumFromZipComment(zf.Comment); sum != "" {
+		header.PAXRecords = map[string]string{paxSHA256: sum}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg:
+	case tar.TypeSymlink:
+		link, err := readZipLink(zf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", zf.Name, err)
+		}
+		header.Linkname = string(link)
+		return header, strings.NewReader(""), nil
+	default:
+		return header, strings.NewReader(""), nil
+	}
+
+	r.current, err = zf.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, r.current, nil
+}
+
+func (r *zipEntryReader) closeCurrent() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
+
+func (r *zipEntryReader) Close() error {
+	err := r.closeCurrent()
+	r.cleanup()
+	return err
+}
+
+// maxZipLinkSize is the maximum size of a symlink target in a zip archive, as PATH_MAX on Linux.
+const maxZipLinkSize = 4096
+
+// readZipLink reads the target of the zip symlink entry zf, stored as its content.
+func readZipLink(zf *zip.File) ([]byte, error) {
+	errTooLong := fmt.Errorf("symlink target exceeds %d bytes", maxZipLinkSize)
+	if zf.UncompressedSize64 > maxZipLinkSize {
+		return nil, errTooLong
+	}
+	r, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// The recorded size may be wrong, so do not trust it.
+	link, err := io.ReadAll(io.LimitReader(r, maxZipLinkSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(link) > maxZipLinkSize {
+		return nil, errTooLong
+	}
+	return link, nil
+}
+
+// toReaderAt returns an io.ReaderAt and its size for in.
+// Regular files and section readers are used as is, any other reader is spooled to a temporary file.
+// The returned cleanup func must be called when done.
+func toReaderAt(in io.Reader) (io.ReaderAt, int64, func(), error) {
+	if sr, ok := in.(*io.SectionReader); ok {
+		offset, err := sr.Seek(0, io.SeekCurrent)
+		if err == nil {
+			return io.NewSectionReader(sr, offset, sr.Size()-offset), sr.Size() - offset, func() {}, nil
+		}
+	}
+	if f, ok := in.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			offset, err := f.Seek(0, io.SeekCurrent)
+			if err == nil {
+				return io.NewSectionReader(f, offset, fi.Size()-offset), fi.Size() - offset, func() {}, nil
+			}
+		}
+	}
+
+	return spoolToTempFile(in)
+}
+
+// spoolToTempFile copies in to a temporary file and returns it and its size.
+// The returned cleanup func must be called when done.
+func spoolToTempFile(in io.Reader) (io.ReaderAt, int64, func(), error) {
+	f, err := os.CreateTemp("", "archivehelpers")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	size, err := io.Copy(f, in)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+
+	return f, size, cleanup, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Bjørn Erik Pedersen
+// SPDX-License-Identifier: MIT
+
+package archivehelpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestZipExtractFromStream(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("a/b/c.txt")
+	c.Assert(err, qt.IsNil)
+	_, err = w.Write([]byte("zipped"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(zw.Close(), qt.IsNil)
+
+	a, err := New(TypeZip)
+	c.Assert(err, qt.IsNil)
+
+	targetDir := t.TempDir()
+	c.Assert(a.Extract(io.NopCloser(&buf), targetDir), qt.IsNil)
+
+	b, err := os.ReadFile(filepath.Join(targetDir, "a", "b", "c.txt"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "zipped")
+}
+
+func TestZipLongSymlink(t *testing.T) {
+	c := qt.New(t)
+
+	symlink := func(target string) io.ReadCloser {
+		return newTestArchive(c, TypeZip, testEntry{name: "link", typeflag: tar.TypeSymlink, linkname: target})
+	}
+
+	a, err := NewWithOptions(TypeZip, Options{Extract: ExtractOptions{Limits: Limits{MaxFileSize: 10}}})
+	c.Assert(err, qt.IsNil)
+
+	// The link target is counted against the limits.
+	err = a.Extract(symlink(strings.Repeat("a", 20)), t.TempDir())
+	var limitErr *LimitError
+	c.Assert(errors.As(err, &limitErr), qt.IsTrue, qt.Commentf("%v", err))
+	c.Assert(limitErr.Limit, qt.Equals, "MaxFileSize")
+
+	// Link targets are never read fully into memory.
+	long := strings.Repeat("a", 50<<20)
+	err = a.Extract(symlink(long), t.TempDir())
+	c.Assert(err, qt.ErrorMatches, ".*symlink target exceeds 4096 bytes")
+	for _, err = range a.Entries(symlink(long)) {
+	}
+	c.Assert(err, qt.ErrorMatches, ".*symlink target exceeds 4096 bytes")
+}
+
+func TestZipCompressionRatioForgedSize(t *testing.T) {
+	c := qt.New(t)
+
+	content := make([]byte, 10<<20)
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.BestCompression)
+	c.Assert(err, qt.IsNil)
+	_, err = fw.Write(content)
+	c.Assert(err, qt.IsNil)
+	c.Assert(fw.Close(), qt.IsNil)
+
+	// The central directory claims a compressed size that hides the compression ratio.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "zeros.txt",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(content),
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: uint64(len(content)),
+	})
+	c.Assert(err, qt.IsNil)
+	_, err = w.Write(compressed.Bytes())
+	c.Assert(err, qt.IsNil)
+	c.Assert(zw.Close(), qt.IsNil)
+
+	a, err := New(TypeZip)
+	c.Assert(err, qt.IsNil)
+	err = a.Extract(io.NopCloser(&buf), t.TempDir())
+	var limitErr *LimitError
+	c.Assert(errors.As(err, &limitErr), qt.IsTrue, qt.Commentf("%v", err))
+	c.Assert(limitErr.Limit, qt.Equals, "MaxCompressionRatio")
+}